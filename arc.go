@@ -2,358 +2,187 @@ package gcache
 
 import (
 	"container/list"
-	"time"
 )
 
 // Constantly balances between LRU and LFU, to improve the combined result.
 type ARC struct {
-	baseCache
-	items map[interface{}]*arcItem
-
-	part int
-	t1   *arcList
-	t2   *arcList
-	b1   *arcList
-	b2   *arcList
+	policyCache
 }
 
 func newARC(cb *CacheBuilder) *ARC {
 	c := &ARC{}
-	buildCache(&c.baseCache, cb)
-
-	c.init()
-	c.loadGroup.cache = c
+	c.setup(cb, TYPE_ARC, &arcPolicy{size: cb.size})
 	return c
 }
 
-func (c *ARC) init() {
-	c.items = make(map[interface{}]*arcItem)
-	c.t1 = newARCList()
-	c.t2 = newARCList()
-	c.b1 = newARCList()
-	c.b2 = newARCList()
+// arcPolicy keeps the keys which were used once in t1 and the ones which were used
+// again in t2. The keys evicted from them are remembered in b1 and b2, and a hit in
+// those ghost lists moves the target size of t1 (part) towards the list which was hit.
+type arcPolicy struct {
+	size int
+	part int
+	t1   *arcList
+	t2   *arcList
+	b1   *arcList
+	b2   *arcList
 }
 
-func (c *ARC) replace(key interface{}) {
-	var old interface{}
-	if (c.t1.Len() > 0 && c.b2.Has(key) && c.t1.Len() == c.part) || (c.t1.Len() > c.part) {
-		old = c.t1.RemoveTail()
-		c.b1.PushFront(old)
-	} else if c.t2.l.Len() > 0 {
-		old = c.t2.RemoveTail()
-		c.b2.PushFront(old)
-	} else {
+func (p *arcPolicy) OnAdd(key interface{}) {
+	if elt := p.b1.Lookup(key); elt != nil {
+		p.part = minInt(p.size, p.part+maxInt(p.b2.Len()/p.b1.Len(), 1))
+		p.b1.Remove(key, elt)
+		p.t2.PushFront(key)
 		return
 	}
-	item, ok := c.items[old]
-	if ok {
-		delete(c.items, old)
-		if c.evictedFunc != nil {
-			c.evictedFunc(item.key, item.value)
-		}
-	}
-}
-
-func (c *ARC) Set(key, value interface{}) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_, err := c.set(key, value)
-	return err
-}
-
-// Set a new key-value pair with an expiration time
-func (c *ARC) SetWithExpire(key, value interface{}, expiration time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, err := c.set(key, value)
-	if err != nil {
-		return err
+	if elt := p.b2.Lookup(key); elt != nil {
+		p.part = maxInt(0, p.part-maxInt(p.b1.Len()/p.b2.Len(), 1))
+		p.b2.Remove(key, elt)
+		p.t2.PushFront(key)
+		return
 	}
-
-	t := c.clock.Now().Add(expiration)
-	item.(*arcItem).expiration = &t
-	return nil
+	p.t1.PushFront(key)
+	p.trim()
 }
 
-func (c *ARC) set(key, value interface{}) (interface{}, error) {
-	var err error
-	if c.serializeFunc != nil {
-		value, err = c.serializeFunc(key, value)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	item, ok := c.items[key]
-	if ok {
-		item.value = value
-	} else {
-		item = &arcItem{
-			clock: c.clock,
-			key:   key,
-			value: value,
-		}
-		c.items[key] = item
-	}
-
-	if c.expiration != nil {
-		t := c.clock.Now().Add(*c.expiration)
-		item.expiration = &t
+func (p *arcPolicy) OnAccess(key interface{}) {
+	if elt := p.t1.Lookup(key); elt != nil {
+		p.t1.Remove(key, elt)
+		p.t2.PushFront(key)
+		return
 	}
-
-	defer func() {
-		if c.addedFunc != nil {
-			c.addedFunc(key, value)
-		}
-	}()
-
-	if c.t1.Has(key) || c.t2.Has(key) {
-		return item, nil
+	if elt := p.t2.Lookup(key); elt != nil {
+		p.t2.MoveToFront(elt)
 	}
+}
 
-	if elt := c.b1.Lookup(key); elt != nil {
-		c.part = minInt(c.size, c.part+maxInt(c.b2.Len()/c.b1.Len(), 1))
-		c.replace(key)
-		c.b1.Remove(key, elt)
-		c.t2.PushFront(key)
-		return item, nil
+// OnRemove remembers key in the ghost list of the list it left,
+// whether it was evicted, expired or removed.
+func (p *arcPolicy) OnRemove(key interface{}) {
+	if elt := p.t1.Lookup(key); elt != nil {
+		p.t1.Remove(key, elt)
+		p.b1.PushFront(key)
+	} else if elt := p.t2.Lookup(key); elt != nil {
+		p.t2.Remove(key, elt)
+		p.b2.PushFront(key)
 	}
+	p.trim()
+}
 
-	if elt := c.b2.Lookup(key); elt != nil {
-		c.part = maxInt(0, c.part-maxInt(c.b1.Len()/c.b2.Len(), 1))
-		c.replace(key)
-		c.b2.Remove(key, elt)
-		c.t2.PushFront(key)
-		return item, nil
+// trim forgets the oldest ghost keys to keep |t1|+|b1| <= size and |t1|+|t2|+|b1|+|b2| <= 2*size.
+func (p *arcPolicy) trim() {
+	for p.b1.Len() > 0 && p.t1.Len()+p.b1.Len() > p.size {
+		p.b1.RemoveTail()
 	}
-
-	if c.t1.Len()+c.b1.Len() == c.size {
-		if c.t1.Len() < c.size {
-			c.b1.RemoveTail()
-			c.replace(key)
-		} else {
-			pop := c.t1.RemoveTail()
-			item, ok := c.items[pop]
-			if ok {
-				delete(c.items, pop)
-				if c.evictedFunc != nil {
-					c.evictedFunc(item.key, item.value)
-				}
-			}
-		}
-	} else {
-		total := c.t1.Len() + c.b1.Len() + c.t2.Len() + c.b2.Len()
-		if total >= c.size {
-			if total == (2 * c.size) {
-				c.b2.RemoveTail()
-			}
-			c.replace(key)
-		}
+	for p.b2.Len() > 0 && p.t1.Len()+p.t2.Len()+p.b1.Len()+p.b2.Len() > 2*p.size {
+		p.b2.RemoveTail()
 	}
-	c.t1.PushFront(key)
-	return item, nil
 }
 
-// Get a value from cache pool using key if it exists. If not exists and it has LoaderFunc, it will generate the value using you have specified LoaderFunc method returns value.
-func (c *ARC) Get(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, true)
+// Victim prefers t1 while it is larger than its target.
+func (p *arcPolicy) Victim() (interface{}, bool) {
+	if p.t1.Len() > 0 && (p.t1.Len() > p.part || p.t2.Len() == 0) {
+		return p.t1.l.Back().Value, true
 	}
-	return v, err
+	if p.t2.Len() > 0 {
+		return p.t2.l.Back().Value, true
+	}
+	return nil, false
 }
 
-// Get a value from cache pool using key if it exists.
-// If it dose not exists key, returns KeyNotFoundError.
-// And send a request which refresh value for specified key if cache object has LoaderFunc.
-func (c *ARC) GetIFPresent(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, false)
-	}
-	return v, err
+func (p *arcPolicy) Reset() {
+	p.part = 0
+	p.t1 = newARCList()
+	p.t2 = newARCList()
+	p.b1 = newARCList()
+	p.b2 = newARCList()
 }
 
-func (c *ARC) get(key interface{}, onLoad bool) (interface{}, error) {
-	v, err := c.getValue(key, onLoad)
-	if err != nil {
-		return nil, err
+// save keeps the t1, t2, b1 and b2 lists and the target size of t1.
+func (p *arcPolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	entries := func(al *arcList) []snapshotEntry {
+		entries := make([]snapshotEntry, 0, al.Len())
+		for e := al.l.Front(); e != nil; e = e.Next() {
+			entries = append(entries, entry(e.Value))
+		}
+		return entries
 	}
-	if c.deserializeFunc != nil {
-		return c.deserializeFunc(key, v)
+	keys := func(al *arcList) []interface{} {
+		keys := make([]interface{}, 0, al.Len())
+		for e := al.l.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value)
+		}
+		return keys
 	}
-	return v, nil
-}
+	part, t1, t2, b1, b2 := p.part, entries(p.t1), entries(p.t2), keys(p.b1), keys(p.b2)
 
-func (c *ARC) getValue(key interface{}, onLoad bool) (interface{}, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if elt := c.t1.Lookup(key); elt != nil {
-		c.t1.Remove(key, elt)
-		item := c.items[key]
-		if !item.IsExpired(nil) {
-			c.t2.PushFront(key)
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return item.value, nil
-		} else {
-			delete(c.items, key)
-			c.b1.PushFront(key)
-			if c.evictedFunc != nil {
-				c.evictedFunc(item.key, item.value)
+	return func(sw *snapshotWriter) error {
+		sw.writeUvarint(uint64(part))
+		for _, entries := range [][]snapshotEntry{t1, t2} {
+			if err := sw.writeEntries(entries); err != nil {
+				return err
 			}
 		}
-	}
-	if elt := c.t2.Lookup(key); elt != nil {
-		item := c.items[key]
-		if !item.IsExpired(nil) {
-			c.t2.MoveToFront(elt)
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return item.value, nil
-		} else {
-			delete(c.items, key)
-			c.t2.Remove(key, elt)
-			c.b2.PushFront(key)
-			if c.evictedFunc != nil {
-				c.evictedFunc(item.key, item.value)
+		for _, keys := range [][]interface{}{b1, b2} {
+			if err := sw.writeKeys(keys); err != nil {
+				return err
 			}
 		}
+		return nil
 	}
-
-	if !onLoad {
-		c.stats.IncrMissCount()
-	}
-	return nil, KeyNotFoundError
 }
 
-func (c *ARC) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderExpireFunc == nil {
-		return nil, KeyNotFoundError
+func (p *arcPolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	part, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
 	}
-	value, _, err := c.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
-		if e != nil {
-			return nil, e
-		}
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		item, err := c.set(key, v)
-		if err != nil {
-			return nil, err
-		}
-		if expiration != nil {
-			t := c.clock.Now().Add(*expiration)
-			item.(*arcItem).expiration = &t
-		}
-		return v, nil
-	}, isWait)
+	t1, err := sr.readEntries()
 	if err != nil {
 		return nil, err
 	}
-	return value, nil
-}
-
-// Remove removes the provided key from the cache.
-func (c *ARC) Remove(key interface{}) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	return c.remove(key)
-}
-
-func (c *ARC) remove(key interface{}) bool {
-	if elt := c.t1.Lookup(key); elt != nil {
-		c.t1.Remove(key, elt)
-		item := c.items[key]
-		delete(c.items, key)
-		c.b1.PushFront(key)
-		if c.evictedFunc != nil {
-			c.evictedFunc(key, item.value)
-		}
-		return true
+	t2, err := sr.readEntries()
+	if err != nil {
+		return nil, err
 	}
-
-	if elt := c.t2.Lookup(key); elt != nil {
-		c.t2.Remove(key, elt)
-		item := c.items[key]
-		delete(c.items, key)
-		c.b2.PushFront(key)
-		if c.evictedFunc != nil {
-			c.evictedFunc(key, item.value)
-		}
-		return true
+	b1, err := sr.readKeys()
+	if err != nil {
+		return nil, err
 	}
-
-	return false
-}
-
-func (c *ARC) keys() []interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	keys := make([]interface{}, len(c.items))
-	var i = 0
-	for k := range c.items {
-		keys[i] = k
-		i++
+	b2, err := sr.readKeys()
+	if err != nil {
+		return nil, err
 	}
-	return keys
-}
 
-// Keys returns a slice of the keys in the cache.
-func (c *ARC) Keys() []interface{} {
-	keys := []interface{}{}
-	for _, k := range c.keys() {
-		_, err := c.GetIFPresent(k)
-		if err == nil {
-			keys = append(keys, k)
+	return func(add func(e snapshotEntry) bool) {
+		p.part = minInt(int(part), p.size)
+		for _, list := range []struct {
+			entries []snapshotEntry
+			al      *arcList
+		}{{t1, p.t1}, {t2, p.t2}} {
+			for _, e := range list.entries {
+				if add(e) {
+					list.al.PushBack(e.key)
+				}
+			}
 		}
-	}
-	return keys
-}
-
-// Returns all key-value pairs in the cache.
-func (c *ARC) GetALL() map[interface{}]interface{} {
-	m := make(map[interface{}]interface{})
-	for _, k := range c.keys() {
-		v, err := c.GetIFPresent(k)
-		if err == nil {
-			m[k] = v
+		// keep the invariants of trim
+		for _, key := range b1 {
+			if p.t1.Len()+p.b1.Len() >= p.size {
+				break
+			}
+			if !p.t1.Has(key) && !p.t2.Has(key) {
+				p.b1.PushBack(key)
+			}
 		}
-	}
-	return m
-}
-
-// Len returns the number of items in the cache.
-func (c *ARC) Len() int {
-	return len(c.GetALL())
-}
-
-// Purge is used to completely clear the cache
-func (c *ARC) Purge() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.purgeVisitorFunc != nil {
-		for _, item := range c.items {
-			c.purgeVisitorFunc(item.key, item.value)
+		for _, key := range b2 {
+			if p.t1.Len()+p.t2.Len()+p.b1.Len()+p.b2.Len() >= 2*p.size {
+				break
+			}
+			if !p.t1.Has(key) && !p.t2.Has(key) && !p.b1.Has(key) {
+				p.b2.PushBack(key)
+			}
 		}
-	}
-
-	c.init()
-}
-
-// returns boolean value whether this item is expired or not.
-func (it *arcItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := it.clock.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+	}, nil
 }
 
 type arcList struct {
@@ -361,13 +190,6 @@ type arcList struct {
 	keys map[interface{}]*list.Element
 }
 
-type arcItem struct {
-	clock      Clock
-	key        interface{}
-	value      interface{}
-	expiration *time.Time
-}
-
 func newARCList() *arcList {
 	return &arcList{
 		l:    list.New(),
@@ -398,6 +220,13 @@ func (al *arcList) PushFront(key interface{}) {
 	al.keys[key] = elt
 }
 
+func (al *arcList) PushBack(key interface{}) {
+	if _, ok := al.keys[key]; ok {
+		return
+	}
+	al.keys[key] = al.l.PushBack(key)
+}
+
 func (al *arcList) Remove(key interface{}, elt *list.Element) {
 	delete(al.keys, key)
 	al.l.Remove(elt)
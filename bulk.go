@@ -0,0 +1,210 @@
+package gcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BulkLoaderFunc loads the values of several keys at once. Keys it does not return are
+// missing, an error fails all of them.
+type BulkLoaderFunc func(keys []interface{}) (map[interface{}]interface{}, error)
+
+// BulkLoaderFunc sets the loader of GetMany, which loads all the keys GetMany misses with one call.
+// Keys which are being loaded by another Get or GetMany are not loaded again, GetMany waits for them.
+// Without a BulkLoaderFunc, GetMany loads the keys one by one with the LoaderFunc.
+func (cb *CacheBuilder) BulkLoaderFunc(bulkLoaderFunc BulkLoaderFunc) *CacheBuilder {
+	cb.bulkLoaderFunc = bulkLoaderFunc
+	return cb
+}
+
+// bulkBatch is a GetMany call on one cache, it holds the values found so far and the misses to load.
+type bulkBatch struct {
+	c *baseCache
+	// keys are the misses claimed for the bulk loader, their calls are finished by complete.
+	keys  []interface{}
+	calls map[interface{}]*call
+	// waiting are the misses which another load is working on.
+	waiting map[interface{}]*call
+	// misses are loaded one by one, without a BulkLoaderFunc.
+	misses []interface{}
+	values map[interface{}]interface{}
+	err    error
+	store  func(key, value interface{}, expiration *time.Duration) error
+	load   func(ctx context.Context, key interface{}, isWait bool) (interface{}, error)
+}
+
+// newBatch looks keys up with get and prepares the load of the misses.
+// store sets a loaded value, load works like getWithLoader.
+func (c *baseCache) newBatch(keys []interface{},
+	get func(key interface{}, onLoad bool) (interface{}, error),
+	store func(key, value interface{}, expiration *time.Duration) error,
+	load func(ctx context.Context, key interface{}, isWait bool) (interface{}, error)) *bulkBatch {
+	b := &bulkBatch{
+		c:      c,
+		values: make(map[interface{}]interface{}, len(keys)),
+		store:  store,
+		load:   load,
+	}
+	var misses []interface{}
+	seen := make(map[interface{}]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		v, err := get(key, false)
+		switch err {
+		case nil:
+			b.values[key] = v
+		case KeyNotFoundError:
+			misses = append(misses, key)
+			continue
+		default:
+			b.fail(err)
+		}
+		c.traceLookup(TraceGet, key, v, err, false)
+	}
+	if c.bulkLoaderFunc == nil {
+		b.misses = misses
+		return b
+	}
+
+	claim := misses[:0]
+	for _, key := range misses {
+		if _, ok := c.cachedError(key); ok {
+			c.stats.incrNegativeHitCount()
+			c.trace(TraceGet, key, TraceMiss, nil)
+			continue
+		}
+		claim = append(claim, key)
+	}
+	var stored map[interface{}]interface{}
+	stored, b.calls, b.waiting = c.loadGroup.claim(claim)
+	for key, v := range stored {
+		// loaded since the lookup
+		b.values[key] = v
+		c.trace(TraceGet, key, TraceLoad, v)
+	}
+	for key := range b.calls {
+		b.keys = append(b.keys, key)
+	}
+	return b
+}
+
+// fail keeps the first error of the batch.
+func (b *bulkBatch) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// loadBulk calls the BulkLoaderFunc, counting it as one load.
+func (c *baseCache) loadBulk(keys []interface{}) (map[interface{}]interface{}, error) {
+	return bulkLoad(c.stats, c.clock, c.bulkLoaderFunc, keys)
+}
+
+// bulkLoad calls f, counting it as one load in st.
+func bulkLoad(st *stats, clock Clock, f BulkLoaderFunc, keys []interface{}) (found map[interface{}]interface{}, err error) {
+	st.startLoad()
+	start := clock.Now()
+	finished := false
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loader panics: %v", r)
+		}
+		if !finished {
+			st.finishLoad(clock.Now().Sub(start), err, true)
+		}
+	}()
+	found, err = f(keys)
+	st.finishLoad(clock.Now().Sub(start), err, false)
+	finished = true
+	return found, err
+}
+
+// complete stores the values of the claimed keys which the bulk loader found, and wakes up
+// the Get calls waiting for them.
+func (b *bulkBatch) complete(found map[interface{}]interface{}, err error) {
+	c := b.c
+	loaded := make(map[interface{}]interface{}, len(b.keys))
+	if err != nil {
+		for _, key := range b.keys {
+			c.cacheError(key, err)
+		}
+	} else {
+		err = b.storeFound(found, loaded)
+	}
+	c.loadGroup.complete(b.calls, loaded, err)
+
+	for _, key := range b.keys {
+		if v, ok := loaded[key]; ok {
+			b.values[key] = v
+			c.trace(TraceGet, key, TraceLoad, v)
+		} else {
+			c.trace(TraceGet, key, TraceMiss, nil)
+		}
+	}
+	if err != nil {
+		b.fail(err)
+	}
+}
+
+// storeFound stores the values of the claimed keys in found and adds them to loaded. A panic of
+// a callback run by store, like AddedFunc, is returned as an error for the keys which are not
+// stored yet, so the calls waiting for them are still completed.
+func (b *bulkBatch) storeFound(found, loaded map[interface{}]interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loader panics: %v", r)
+		}
+	}()
+	for _, key := range b.keys {
+		v, ok := found[key]
+		if !ok {
+			continue
+		}
+		if serr := b.store(key, v, nil); serr != nil {
+			b.fail(serr)
+			continue
+		}
+		loaded[key] = v
+	}
+	return nil
+}
+
+// finish loads the misses one by one if there is no BulkLoaderFunc, waits for the keys
+// which other calls are loading, and returns the values of the batch.
+func (b *bulkBatch) finish() (map[interface{}]interface{}, error) {
+	c := b.c
+	for _, key := range b.misses {
+		v, err := b.load(context.Background(), key, true)
+		c.traceLookup(TraceGet, key, v, err, true)
+		b.add(key, v, err)
+	}
+	for key, call := range b.waiting {
+		v, _, err := c.loadGroup.wait(context.Background(), key, call, false)
+		c.traceLookup(TraceGet, key, v, err, true)
+		b.add(key, v, err)
+	}
+	return b.values, b.err
+}
+
+func (b *bulkBatch) add(key, v interface{}, err error) {
+	switch err {
+	case nil:
+		b.values[key] = v
+	case KeyNotFoundError:
+	default:
+		b.fail(err)
+	}
+}
+
+// getMany runs a batch which was started by newBatch.
+func (c *baseCache) getMany(b *bulkBatch) (map[interface{}]interface{}, error) {
+	if len(b.keys) > 0 {
+		found, err := c.loadBulk(b.keys)
+		b.complete(found, err)
+	}
+	return b.finish()
+}
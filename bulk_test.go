@@ -0,0 +1,315 @@
+package gcache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bulkRecorder is a BulkLoaderFunc which returns "v"+key for every key but "missing",
+// and remembers the keys of every call.
+type bulkRecorder struct {
+	mu    sync.Mutex
+	calls [][]interface{}
+}
+
+func (r *bulkRecorder) load(keys []interface{}) (map[interface{}]interface{}, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, keys)
+	r.mu.Unlock()
+	m := make(map[interface{}]interface{})
+	for _, k := range keys {
+		if k != "missing" {
+			m[k] = fmt.Sprint("v", k)
+		}
+	}
+	return m, nil
+}
+
+func sortedKeys(keys []interface{}) []string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = fmt.Sprint(k)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestGetMany(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		r := &bulkRecorder{}
+		gc := New(10).
+			EvictType(tp).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				t.Errorf("%s: unexpected load of %v", tp, key)
+				return nil, KeyNotFoundError
+			}).
+			BulkLoaderFunc(r.load).
+			Build()
+		gc.Set("a", "stored")
+
+		m, err := gc.GetMany([]interface{}{"a", "b", "c", "b", "missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[interface{}]interface{}{"a": "stored", "b": "vb", "c": "vc"}
+		if len(m) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", tp, expected, m)
+		}
+		for k, v := range expected {
+			if m[k] != v {
+				t.Errorf("%s: expected %v for %v, got %v", tp, v, k, m[k])
+			}
+		}
+		if len(r.calls) != 1 || fmt.Sprint(sortedKeys(r.calls[0])) != "[b c missing]" {
+			t.Errorf("%s: expected one bulk load of the misses, got %v", tp, r.calls)
+		}
+		if v, err := gc.Get("b"); v != "vb" || err != nil {
+			t.Errorf("%s: expected the loaded value to be stored, got %v, %v", tp, v, err)
+		}
+		if st := gc.Stats(); st.HitCount != 2 || st.MissCount != 3 || st.LoadSuccessCount != 1 {
+			t.Errorf("%s: expected 2 hits, 3 misses and 1 load, got %+v", tp, st)
+		}
+
+		gc.GetMany([]interface{}{"b", "c"})
+		if len(r.calls) != 1 {
+			t.Errorf("%s: expected no load for stored keys, got %v", tp, r.calls)
+		}
+	}
+}
+
+func TestGetManyWithoutBulkLoader(t *testing.T) {
+	loads := 0
+	gc := New(10).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			loads++
+			return key, nil
+		}).
+		Build()
+	m, err := gc.GetMany([]interface{}{1, 2, 3})
+	if err != nil || len(m) != 3 || loads != 3 {
+		t.Errorf("expected 3 values from 3 loads, got %v, %v after %d loads", m, err, loads)
+	}
+
+	gc = New(10).LRU().Build()
+	gc.Set(1, 1)
+	m, err = gc.GetMany([]interface{}{1, 2})
+	if err != nil || len(m) != 1 || m[1] != 1 {
+		t.Errorf("expected only the stored value, got %v, %v", m, err)
+	}
+}
+
+func TestGetManyError(t *testing.T) {
+	errDown := errors.New("backend down")
+	loads := 0
+	gc := New(10).
+		LRU().
+		NegativeCacheTTL(time.Minute).
+		BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			loads++
+			return nil, errDown
+		}).
+		Build()
+	gc.Set("a", 1)
+	m, err := gc.GetMany([]interface{}{"a", "b"})
+	if err != errDown || len(m) != 1 || m["a"] != 1 {
+		t.Errorf("expected the stored value and errDown, got %v, %v", m, err)
+	}
+	gc.GetMany([]interface{}{"b"})
+	if loads != 1 {
+		t.Errorf("expected the error to be cached, got %d loads", loads)
+	}
+	if n := gc.Stats().NegativeHitCount; n != 1 {
+		t.Errorf("expected 1 negative hit, got %d", n)
+	}
+}
+
+func TestGetManyPanic(t *testing.T) {
+	gc := New(10).
+		LRU().
+		BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			panic("boom")
+		}).
+		Build()
+	if _, err := gc.GetMany([]interface{}{"a"}); err == nil {
+		t.Error("expected an error")
+	}
+	if n := gc.Stats().LoaderPanicCount; n != 1 {
+		t.Errorf("expected 1 loader panic, got %d", n)
+	}
+	// the key is not stuck in flight
+	if _, err := gc.GetMany([]interface{}{"a"}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGetManyWaitsForInFlightGet(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r := &bulkRecorder{}
+	gc := New(10).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return "loaded", nil
+		}).
+		BulkLoaderFunc(r.load).
+		Build()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gc.Get("x")
+	}()
+	<-started
+
+	result := make(chan map[interface{}]interface{})
+	go func() {
+		m, _ := gc.GetMany([]interface{}{"x", "y"})
+		result <- m
+	}()
+	if !waitFor(NewFakeClock(), 0, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.calls) == 1
+	}) {
+		t.Fatal("timed out waiting for the bulk load")
+	}
+	close(release)
+	m := <-result
+	<-done
+
+	if fmt.Sprint(r.calls) != "[[y]]" {
+		t.Errorf("expected only y to be bulk loaded, got %v", r.calls)
+	}
+	if m["x"] != "loaded" || m["y"] != "vy" {
+		t.Errorf("unexpected values %v", m)
+	}
+}
+
+func TestGetWaitsForInFlightGetMany(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	gc := New(10).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			t.Errorf("unexpected load of %v", key)
+			return nil, KeyNotFoundError
+		}).
+		BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			close(started)
+			<-release
+			return map[interface{}]interface{}{"x": "bulk"}, nil
+		}).
+		Build()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gc.GetMany([]interface{}{"x"})
+	}()
+	<-started
+
+	result := make(chan interface{})
+	go func() {
+		v, _ := gc.Get("x")
+		result <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if v := <-result; v != "bulk" {
+		t.Errorf("expected the value of the bulk load, got %v", v)
+	}
+	<-done
+}
+
+func TestGetManyPanickingAddedFunc(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	gc := New(10).
+		LRU().
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			t.Errorf("unexpected load of %v", key)
+			return nil, KeyNotFoundError
+		}).
+		BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+			close(started)
+			<-release
+			return map[interface{}]interface{}{"x": "bulk"}, nil
+		}).
+		AddedFunc(func(key, value interface{}) {
+			panic("boom")
+		}).
+		Build()
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := gc.GetMany([]interface{}{"x"})
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := gc.Get("x")
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("expected an error")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("a call waiting for the bulk load is stuck")
+		}
+	}
+}
+
+func TestShardedGetMany(t *testing.T) {
+	r := &bulkRecorder{}
+	gc := New(100).LRU().Shards(4).BulkLoaderFunc(r.load).Build()
+	var keys []interface{}
+	for i := 0; i < 20; i++ {
+		keys = append(keys, fmt.Sprint(i))
+	}
+	gc.Set("0", "stored")
+	m, err := gc.GetMany(keys)
+	if err != nil || len(m) != 20 || m["0"] != "stored" || m["7"] != "v7" {
+		t.Errorf("unexpected values %v, %v", m, err)
+	}
+	if len(r.calls) != 1 || len(r.calls[0]) != 19 {
+		t.Errorf("expected a single bulk load of 19 keys, got %v", r.calls)
+	}
+	if st := gc.Stats(); st.LoadSuccessCount != 1 || st.InFlightLoads != 0 {
+		t.Errorf("expected one load, got %+v", st)
+	}
+	for _, s := range gc.(*ShardedCache).shards {
+		if n := s.Stats().LoadSuccessCount; n != 0 {
+			t.Errorf("the bulk load was counted by a shard: %v", n)
+		}
+	}
+	gc.ResetStats()
+	if n := gc.Stats().LoadSuccessCount; n != 0 {
+		t.Errorf("%v != %v", n, 0)
+	}
+}
+
+func TestTypedGetMany(t *testing.T) {
+	tc := NewTyped[int, string](10).
+		LRU().
+		BulkLoaderFunc(func(keys []int) (map[int]string, error) {
+			m := make(map[int]string)
+			for _, k := range keys {
+				m[k] = fmt.Sprint(k * 10)
+			}
+			return m, nil
+		}).
+		Build()
+	m, err := tc.GetMany([]int{1, 2})
+	if err != nil || m[1] != "10" || m[2] != "20" {
+		t.Errorf("unexpected values %v, %v", m, err)
+	}
+}
@@ -1,17 +1,25 @@
 package gcache
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 )
 
 const (
-	TYPE_SIMPLE = "simple"
-	TYPE_LRU    = "lru"
-	TYPE_LFU    = "lfu"
-	TYPE_ARC    = "arc"
+	TYPE_SIMPLE  = "simple"
+	TYPE_LRU     = "lru"
+	TYPE_LFU     = "lfu"
+	TYPE_ARC     = "arc"
+	TYPE_TINYLFU = "tinylfu"
+	TYPE_2Q      = "2q"
+	TYPE_SLRU    = "slru"
+	TYPE_SIEVE   = "sieve"
+	TYPE_S3FIFO  = "s3fifo"
+	TYPE_POLICY  = "policy"
 
 	DefaultMaxSize = 100000
 )
@@ -19,6 +27,7 @@ const (
 var KeyNotFoundError = errors.New("key not found ")
 var EmptyErr = errors.New("cache is empty")
 var ReachedMaxSizeErr = errors.New("reached max size")
+var NotComparableErr = errors.New("value is not comparable")
 
 //supports an ordered and unordered  way , default is unordered
 //ordered cache just support simple cache in this version
@@ -26,13 +35,23 @@ type Cache interface {
 	Set(interface{}, interface{}) error //don't usu this in a ordered queue
 	SetWithExpire(interface{}, interface{}, time.Duration) error
 	Get(interface{}) (interface{}, error)
+	GetCtx(context.Context, interface{}) (interface{}, error)
 	GetIFPresent(interface{}) (interface{}, error)
+	GetMany([]interface{}) (map[interface{}]interface{}, error) //GetMany loads all misses with one BulkLoaderFunc call
 	GetALL() map[interface{}]interface{}
 	get(interface{}, bool) (interface{}, error)
+	GetOrSet(interface{}, interface{}) (interface{}, bool, error) //GetOrSet returns the stored value, or sets the given one
+	SetIfAbsent(interface{}, interface{}) (bool, error)
+	Compute(interface{}, ComputeFunc) (interface{}, error) //Compute atomically replaces a value
+	CompareAndSwap(interface{}, interface{}, interface{}) (bool, error)
 	Remove(interface{}) bool
 	Purge()
 	Keys() []interface{}
 	Len() int
+	Save(io.Writer) error //Save writes the contents of the cache as a snapshot
+	Load(io.Reader) error //Load replaces the contents of the cache with a snapshot
+	Close() error         //Close stops the background janitor, if any
+	Capacity() int        //Capacity returns the maximum number of items, 0 means unlimited
 
 	statsAccessor
 }
@@ -40,16 +59,19 @@ type Cache interface {
 type OrderedCache interface {
 	//for ordered (queues) cache,
 	EnQueue(interface{}, interface{}) error
-	EnQueueBatch([]interface{}, []interface{}) error //EnQueueBatch if searchFunc is not nil , keys are  required sorted
+	EnQueueBatch([]interface{}, []interface{}) error
 	DeQueue() (interface{}, interface{}, error)
 	DeQueueBatch(count int) ([]interface{}, []interface{}, error)
+	DeQueueWait(context.Context) (interface{}, interface{}, error) //DeQueueWait blocks until an item is queued
+	DeQueueBatchWait(ctx context.Context, max int, maxWait time.Duration) ([]interface{}, []interface{}, error)
 	OrderedKeys() []interface{}
 	MoveFront(interface{}) error               //move an element to front
 	GetTop() (interface{}, interface{}, error) //get top element
 	Prepend(interface{}, interface{}) error
-	PrependBatch([]interface{}, []interface{}) error //PrependBatch if searchFunc is not nil , keys are  required sorted
+	PrependBatch([]interface{}, []interface{}) error
 	RemoveExpired(allowFailCount int) error
 	Get(interface{}) (interface{}, error)
+	GetCtx(context.Context, interface{}) (interface{}, error)
 	GetIFPresent(interface{}) (interface{}, error)
 	GetALL() map[interface{}]interface{}
 	GetKeysAndValues() ([]interface{}, []interface{})
@@ -61,13 +83,30 @@ type OrderedCache interface {
 	Keys() []interface{}
 	Len() int
 	Sort()
+	Save(io.Writer) error
+	Load(io.Reader) error
+	Close() error
+	Capacity() int
+	QueueLen() int //QueueLen returns the number of queued keys without dropping expired items first
+	//for a cache sorted by the SearchCompareFunction
+	Range(from, to interface{}, fn func(k, v interface{}) bool) //Range visits the keys with values between from and to
+	Floor(interface{}) (interface{}, interface{}, error)        //Floor returns the last key with a value not above the given one
+	Ceiling(interface{}) (interface{}, interface{}, error)      //Ceiling returns the first key with a value not below the given one
+	Rank(interface{}) int                                       //Rank returns the position of a key, -1 if it is missing
+	At(int) (interface{}, interface{}, error)                   //At returns the key at a position
+	UpdateScore(interface{}, interface{}) error                 //UpdateScore replaces the value of a key and moves it
+	TopN(int) ([]interface{}, []interface{})                    //TopN returns the first keys of the sort order
+	BottomN(int) ([]interface{}, []interface{})                 //BottomN returns the last keys of the sort order
+	RankOf(interface{}) (int, error)                            //RankOf returns the position of a live key
+	RemoveRangeByRank(start, stop int) int                      //RemoveRangeByRank removes the keys between two positions
 	statsAccessor
 }
 
 type baseCache struct {
 	clock            Clock
 	size             int
-	loaderExpireFunc LoaderExpireFunc
+	loaderFunc       LoaderCtxExpireFunc
+	bulkLoaderFunc   BulkLoaderFunc
 	expireFunction   ExpiredFunction
 	evictedFunc      EvictedFunc
 	purgeVisitorFunc PurgeVisitorFunc
@@ -79,12 +118,26 @@ type baseCache struct {
 	loadGroup        Group
 	sortKeysFunc	SortKeysFunction
 	searchCmpFunc       SearchCompareFunction
+	keyCodec         Codec
+	valueCodec       Codec
+	janitor          *janitor
+	// expiries indexes the keys which have an expiration time.
+	expiries         expiryHeap
+	weigher          Weigher
+	maxWeight        int64
+	// weight is the sum of the weights of all items.
+	weight           int64
+	refreshAfter     time.Duration
+	negatives        *negativeCache
+	recorder         *traceRecorder
 	*stats
 }
 
 type (
 	LoaderFunc       func(interface{}) (interface{}, error)
 	LoaderExpireFunc func(interface{}) (interface{}, *time.Duration, error)
+	LoaderCtxFunc       func(context.Context, interface{}) (interface{}, error)
+	LoaderCtxExpireFunc func(context.Context, interface{}) (interface{}, *time.Duration, error)
 	EvictedFunc      func(interface{}, interface{})
 	PurgeVisitorFunc func(interface{}, interface{})
 	AddedFunc        func(interface{}, interface{})
@@ -93,13 +146,15 @@ type (
 	ExpiredFunction  func(interface{}) bool
 	SortKeysFunction func ([]interface{}, []interface{} , func (interface{}) (interface{}, bool))([]interface{}, bool)
 	SearchCompareFunction   func (value interface{} ,anotherValue interface{} )(int)
+	Weigher          func(interface{}, interface{}) int64
 )
 
 type CacheBuilder struct {
 	clock            Clock
 	tp               string
 	size             int
-	loaderExpireFunc LoaderExpireFunc
+	loaderFunc       LoaderCtxExpireFunc
+	bulkLoaderFunc   BulkLoaderFunc
 	evictedFunc      EvictedFunc
 	purgeVisitorFunc PurgeVisitorFunc
 	addedFunc        AddedFunc
@@ -109,6 +164,25 @@ type CacheBuilder struct {
 	expireFunction   ExpiredFunction
 	sortKeysFunction  SortKeysFunction
 	searchCmpFunc        SearchCompareFunction
+	keyCodec         Codec
+	valueCodec       Codec
+	shards           int
+	shardSize        int
+	// shardCount is the item count a ShardedCache shares with its shards
+	shardCount       *int64
+	// shardWeight is the weight a ShardedCache shares with its shards
+	shardWeight      *int64
+	cleanupInterval  time.Duration
+	weigher          Weigher
+	maxWeight        int64
+	policy           EvictionPolicy
+	refreshAfter     time.Duration
+	negativeTTL      time.Duration
+	negativeFunc     NegativeCacheFunc
+	traceWriter      io.Writer
+	traceRate        float64
+	traceSize        Weigher
+	recorder         *traceRecorder
 }
 
 // using ordered cache if orderedcache  is true
@@ -128,7 +202,7 @@ func (cb *CacheBuilder) Clock(clock Clock) *CacheBuilder {
 // Set a loader function.
 // loaderFunc: create a new value with this function if cached value is expired.
 func (cb *CacheBuilder) LoaderFunc(loaderFunc LoaderFunc) *CacheBuilder {
-	cb.loaderExpireFunc = func(k interface{}) (interface{}, *time.Duration, error) {
+	cb.loaderFunc = func(_ context.Context, k interface{}) (interface{}, *time.Duration, error) {
 		v, err := loaderFunc(k)
 		return v, nil, err
 	}
@@ -139,7 +213,27 @@ func (cb *CacheBuilder) LoaderFunc(loaderFunc LoaderFunc) *CacheBuilder {
 // loaderExpireFunc: create a new value with this function if cached value is expired.
 // If nil returned instead of time.Duration from loaderExpireFunc than value will never expire.
 func (cb *CacheBuilder) LoaderExpireFunc(loaderExpireFunc LoaderExpireFunc) *CacheBuilder {
-	cb.loaderExpireFunc = loaderExpireFunc
+	cb.loaderFunc = func(_ context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		return loaderExpireFunc(k)
+	}
+	return cb
+}
+
+// Set a loader function which receives the context of the caller that started the load.
+// The context keeps the caller's values, but it is only cancelled once every caller
+// waiting for the key has given up.
+func (cb *CacheBuilder) LoaderCtxFunc(loaderCtxFunc LoaderCtxFunc) *CacheBuilder {
+	cb.loaderFunc = func(ctx context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		v, err := loaderCtxFunc(ctx, k)
+		return v, nil, err
+	}
+	return cb
+}
+
+// Set a loader function with context and expiration.
+// If nil returned instead of time.Duration from loaderCtxExpireFunc than value will never expire.
+func (cb *CacheBuilder) LoaderCtxExpireFunc(loaderCtxExpireFunc LoaderCtxExpireFunc) *CacheBuilder {
+	cb.loaderFunc = loaderCtxExpireFunc
 	return cb
 }
 
@@ -168,6 +262,38 @@ func (cb *CacheBuilder) ARC() *CacheBuilder {
 	return cb.EvictType(TYPE_ARC)
 }
 
+// TinyLFU builds a W-TinyLFU cache, which keeps a frequently used working set through scans.
+func (cb *CacheBuilder) TinyLFU() *CacheBuilder {
+	return cb.EvictType(TYPE_TINYLFU)
+}
+
+// TwoQueue builds a 2Q cache, items which are used only once never reach its main LRU queue.
+func (cb *CacheBuilder) TwoQueue() *CacheBuilder {
+	return cb.EvictType(TYPE_2Q)
+}
+
+// SLRU builds a segmented LRU cache, items are protected from eviction once they are used twice.
+func (cb *CacheBuilder) SLRU() *CacheBuilder {
+	return cb.EvictType(TYPE_SLRU)
+}
+
+// SIEVE builds a SIEVE cache, its lookups do not reorder items and only need the read lock.
+func (cb *CacheBuilder) SIEVE() *CacheBuilder {
+	return cb.EvictType(TYPE_SIEVE)
+}
+
+// S3FIFO builds a S3-FIFO cache, its lookups do not reorder items and only need the read lock.
+func (cb *CacheBuilder) S3FIFO() *CacheBuilder {
+	return cb.EvictType(TYPE_S3FIFO)
+}
+
+// Policy builds a cache which evicts items following p.
+// p belongs to the built cache, it can not be shared with another cache and the cache can not be sharded.
+func (cb *CacheBuilder) Policy(p EvictionPolicy) *CacheBuilder {
+	cb.policy = p
+	return cb.EvictType(TYPE_POLICY)
+}
+
 func (cb *CacheBuilder) EvictedFunc(evictedFunc EvictedFunc) *CacheBuilder {
 	cb.evictedFunc = evictedFunc
 	return cb
@@ -205,6 +331,53 @@ func (cb *CacheBuilder) SearchCompareFunction( searchFunction  SearchCompareFunc
 	return cb
 }
 
+// Set the codec used for keys by Save and Load, GobCodec is used by default.
+func (cb *CacheBuilder) KeyCodec(codec Codec) *CacheBuilder {
+	cb.keyCodec = codec
+	return cb
+}
+
+// Set the codec used for values by Save and Load, GobCodec is used by default.
+// Values are saved as they are stored, i.e. after SerializeFunc.
+func (cb *CacheBuilder) ValueCodec(codec Codec) *CacheBuilder {
+	cb.valueCodec = codec
+	return cb
+}
+
+// Shards spreads the keys across n independent caches of the configured type,
+// each guarded by its own lock. The size given to New stays a global limit.
+func (cb *CacheBuilder) Shards(n int) *CacheBuilder {
+	cb.shards = n
+	return cb
+}
+
+// ShardSize limits the number of items of every shard, by default each shard holds ceil(size/shards) items.
+func (cb *CacheBuilder) ShardSize(size int) *CacheBuilder {
+	cb.shardSize = size
+	return cb
+}
+
+// CleanupInterval starts a background janitor which removes expired items every
+// interval of the cache clock and fires EvictedFunc for them. Stop it with Close.
+func (cb *CacheBuilder) CleanupInterval(interval time.Duration) *CacheBuilder {
+	cb.cleanupInterval = interval
+	return cb
+}
+
+// Weigher sets the function which tells the weight of an item, e.g. the size of its value in bytes.
+// It is called with the value as stored, after SerializeFunc.
+func (cb *CacheBuilder) Weigher(weigher Weigher) *CacheBuilder {
+	cb.weigher = weigher
+	return cb
+}
+
+// MaxWeight limits the sum of the item weights, items are evicted until a new one fits.
+// Without a Weigher every item weighs 1. The size given to New still applies.
+func (cb *CacheBuilder) MaxWeight(maxWeight int64) *CacheBuilder {
+	cb.maxWeight = maxWeight
+	return cb
+}
+
 func (cb *CacheBuilder) Expiration(expiration time.Duration) *CacheBuilder {
 	cb.expiration = &expiration
 	return cb
@@ -214,16 +387,43 @@ func (cb *CacheBuilder) Build() Cache {
 	if cb.size <= 0 && cb.tp != TYPE_SIMPLE {
 		panic("gcache: Cache size <= 0")
 	}
+	if cb.tp == TYPE_POLICY && cb.policy == nil {
+		panic("gcache: Policy is nil")
+	}
+	if cb.traceWriter != nil {
+		// the shards share the recorder, the next Build gets its own
+		cb.recorder = newTraceRecorder(cb)
+		defer func() { cb.recorder = nil }()
+	}
+	if cb.shards > 1 {
+		if cb.tp == TYPE_POLICY {
+			panic("gcache: cache with a custom policy can not be sharded")
+		}
+		return newShardedCache(cb)
+	}
 
 	return cb.build()
 }
 
 func (cb *CacheBuilder) BuildOrderedCache() OrderedCache {
+	cb.checkOrdered()
+	cb.tp = TYPE_SIMPLE
+	return cb.buildOrderedCache()
+}
+
+func (cb *CacheBuilder) checkOrdered() {
 	if cb.size <= 0 {
 		panic("gcache: Cache size <= 0")
 	}
-	cb.tp = TYPE_SIMPLE
-	return cb.buildOrderedCache()
+	if cb.shards > 1 {
+		panic("gcache: ordered cache can not be sharded")
+	}
+	if cb.refreshAfter > 0 {
+		panic("gcache: ordered cache does not support RefreshAfter")
+	}
+	if cb.traceWriter != nil {
+		panic("gcache: ordered cache does not support TraceRecorder")
+	}
 }
 
 func (cb *CacheBuilder) buildOrderedCache() OrderedCache {
@@ -248,6 +448,18 @@ func (cb *CacheBuilder) build() Cache {
 		return newLFUCache(cb)
 	case TYPE_ARC:
 		return newARC(cb)
+	case TYPE_TINYLFU:
+		return newTinyLFUCache(cb)
+	case TYPE_2Q:
+		return newTwoQueueCache(cb)
+	case TYPE_SLRU:
+		return newSLRUCache(cb)
+	case TYPE_SIEVE:
+		return newSieveCache(cb)
+	case TYPE_S3FIFO:
+		return newS3FIFOCache(cb)
+	case TYPE_POLICY:
+		return newPolicyCache(cb)
 	default:
 		panic("gcache: Unknown type " + cb.tp)
 	}
@@ -256,7 +468,8 @@ func (cb *CacheBuilder) build() Cache {
 func buildCache(c *baseCache, cb *CacheBuilder) {
 	c.clock = cb.clock
 	c.size = cb.size
-	c.loaderExpireFunc = cb.loaderExpireFunc
+	c.loaderFunc = cb.loaderFunc
+	c.bulkLoaderFunc = cb.bulkLoaderFunc
 	c.expiration = cb.expiration
 	c.addedFunc = cb.addedFunc
 	c.deserializeFunc = cb.deserializeFunc
@@ -266,18 +479,55 @@ func buildCache(c *baseCache, cb *CacheBuilder) {
 	c.expireFunction = cb.expireFunction
 	c.sortKeysFunc = cb.sortKeysFunction
 	c.searchCmpFunc =  cb.searchCmpFunc
+	c.keyCodec = cb.keyCodec
+	if c.keyCodec == nil {
+		c.keyCodec = GobCodec{}
+	}
+	c.valueCodec = cb.valueCodec
+	if c.valueCodec == nil {
+		c.valueCodec = GobCodec{}
+	}
+	c.weigher = cb.weigher
+	c.maxWeight = cb.maxWeight
+	c.refreshAfter = cb.refreshAfter
+	c.negatives = newNegativeCache(cb)
+	c.recorder = cb.recorder
 	c.stats = &stats{}
 }
 
+// Capacity returns the maximum number of items, 0 means unlimited.
+func (c *baseCache) Capacity() int {
+	return c.size
+}
+
 // load a new value using by specified key.
-func (c *baseCache) load(key interface{}, cb func(interface{}, *time.Duration, error) (interface{}, error), isWait bool) (interface{}, bool, error) {
-	v, called, err := c.loadGroup.Do(key, func() (v interface{}, e error) {
+func (c *baseCache) load(ctx context.Context, key interface{}, cb func(interface{}, *time.Duration, error) (interface{}, error), isWait bool) (interface{}, bool, error) {
+	if err, ok := c.cachedError(key); ok {
+		c.stats.incrNegativeHitCount()
+		if !isWait {
+			return nil, false, KeyNotFoundError
+		}
+		return nil, false, err
+	}
+	v, called, err := c.loadGroup.DoCtx(ctx, key, func(ctx context.Context) (v interface{}, e error) {
+		c.stats.startLoad()
+		start := c.clock.Now()
+		finished := false
 		defer func() {
 			if r := recover(); r != nil {
 				e = fmt.Errorf("loader panics: %v", r)
 			}
+			if !finished {
+				c.stats.finishLoad(c.clock.Now().Sub(start), e, true)
+			}
 		}()
-		return cb(c.loaderExpireFunc(key))
+		v, expiration, err := c.loaderFunc(ctx, key)
+		c.stats.finishLoad(c.clock.Now().Sub(start), err, false)
+		finished = true
+		if err != nil {
+			c.cacheError(key, err)
+		}
+		return cb(v, expiration, err)
 	}, isWait)
 	if err != nil {
 		return nil, called, err
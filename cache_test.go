@@ -2,6 +2,7 @@ package gcache
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,11 @@ func TestLoaderFunc(t *testing.T) {
 		New(size).LRU(),
 		New(size).LFU(),
 		New(size).ARC(),
+		New(size).TinyLFU(),
+		New(size).TwoQueue(),
+		New(size).SLRU(),
+		New(size).SIEVE(),
+		New(size).S3FIFO(),
 	}
 	for _, builder := range testCaches {
 		var testCounter int64
@@ -55,6 +61,11 @@ func TestLoaderExpireFuncWithoutExpire(t *testing.T) {
 		New(size).LRU(),
 		New(size).LFU(),
 		New(size).ARC(),
+		New(size).TinyLFU(),
+		New(size).TwoQueue(),
+		New(size).SLRU(),
+		New(size).SIEVE(),
+		New(size).S3FIFO(),
 	}
 	for _, builder := range testCaches {
 		var testCounter int64
@@ -94,6 +105,11 @@ func TestLoaderExpireFuncWithExpire(t *testing.T) {
 		New(size).LRU(),
 		New(size).LFU(),
 		New(size).ARC(),
+		New(size).TinyLFU(),
+		New(size).TwoQueue(),
+		New(size).SLRU(),
+		New(size).SIEVE(),
+		New(size).S3FIFO(),
 	}
 	for _, builder := range testCaches {
 		var testCounter int64
@@ -158,6 +174,26 @@ func TestLoaderPurgeVisitorFunc(t *testing.T) {
 			name:         "arc",
 			cacheBuilder: New(size).ARC(),
 		},
+		{
+			name:         "tinylfu",
+			cacheBuilder: New(size).TinyLFU(),
+		},
+		{
+			name:         "2q",
+			cacheBuilder: New(size).TwoQueue(),
+		},
+		{
+			name:         "slru",
+			cacheBuilder: New(size).SLRU(),
+		},
+		{
+			name:         "sieve",
+			cacheBuilder: New(size).SIEVE(),
+		},
+		{
+			name:         "s3fifo",
+			cacheBuilder: New(size).S3FIFO(),
+		},
 	}
 
 	for _, test := range tests {
@@ -213,6 +249,11 @@ func TestDeserializeFunc(t *testing.T) {
 		{TYPE_LRU},
 		{TYPE_LFU},
 		{TYPE_ARC},
+		{TYPE_TINYLFU},
+		{TYPE_2Q},
+		{TYPE_SLRU},
+		{TYPE_SIEVE},
+		{TYPE_S3FIFO},
 	}
 
 	for _, cs := range cases {
@@ -265,3 +306,55 @@ func TestDeserializeFunc(t *testing.T) {
 		}
 	}
 }
+
+func TestGetCtx(t *testing.T) {
+	size := 2
+	var testCaches = []*CacheBuilder{
+		New(size).Simple(),
+		New(size).LRU(),
+		New(size).LFU(),
+		New(size).ARC(),
+		New(size).TinyLFU(),
+		New(size).TwoQueue(),
+		New(size).SLRU(),
+		New(size).SIEVE(),
+		New(size).S3FIFO(),
+	}
+	for _, builder := range testCaches {
+		release := make(chan struct{})
+		cache := builder.
+			LoaderCtxFunc(func(ctx context.Context, key interface{}) (interface{}, error) {
+				if ctx.Value("caller") != "first" {
+					t.Errorf("loader should see the caller context values")
+				}
+				<-release
+				return "value", nil
+			}).
+			Build()
+
+		result := make(chan interface{})
+		go func() {
+			v, err := cache.GetCtx(context.WithValue(context.Background(), "caller", "first"), "key")
+			if err != nil {
+				t.Error(err)
+			}
+			result <- v
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := cache.GetCtx(ctx, "key")
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("err should be %v, not %v", context.DeadlineExceeded, err)
+		}
+
+		close(release)
+		if v := <-result; v != "value" {
+			t.Errorf("%v != %v", v, "value")
+		}
+		if v, err := cache.GetIFPresent("key"); err != nil || v != "value" {
+			t.Errorf("loaded value should be cached, got %v %v", v, err)
+		}
+	}
+}
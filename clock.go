@@ -9,6 +9,23 @@ type Clock interface {
 	Now() time.Time
 }
 
+// timerClock is implemented by clocks which can also drive timers,
+// the background janitor uses it to follow a FakeClock in tests.
+type timerClock interface {
+	// timer fires once the clock has been advanced by at least d, stop forgets it.
+	timer(d time.Duration) (c <-chan time.Time, stop func())
+}
+
+// after waits for d on clock if it drives timers, on the real clock otherwise.
+// stop releases the timer when it is not needed anymore.
+func after(clock Clock, d time.Duration) (c <-chan time.Time, stop func()) {
+	if tc, ok := clock.(timerClock); ok {
+		return tc.timer(d)
+	}
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
 type RealClock struct{}
 
 func NewRealClock() Clock {
@@ -34,11 +51,17 @@ func NewFakeClock() FakeClock {
 }
 
 type fakeclock struct {
-	now time.Time
+	now     time.Time
+	waiters []fakeWaiter
 
 	mutex sync.RWMutex
 }
 
+type fakeWaiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
 func (fc *fakeclock) Now() time.Time {
 	fc.mutex.RLock()
 	defer fc.mutex.RUnlock()
@@ -46,8 +69,41 @@ func (fc *fakeclock) Now() time.Time {
 	return t
 }
 
+func (fc *fakeclock) timer(d time.Duration) (<-chan time.Time, func()) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- fc.now
+		return ch, func() {}
+	}
+	fc.waiters = append(fc.waiters, fakeWaiter{until: fc.now.Add(d), ch: ch})
+	return ch, func() { fc.stop(ch) }
+}
+
+// stop removes the waiter of ch if it has not fired yet.
+func (fc *fakeclock) stop(ch chan time.Time) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	for i, w := range fc.waiters {
+		if w.ch == ch {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
 func (fc *fakeclock) Advance(d time.Duration) {
 	fc.mutex.Lock()
 	defer fc.mutex.Unlock()
 	fc.now = fc.now.Add(d)
+	waiters := fc.waiters[:0]
+	for _, w := range fc.waiters {
+		if w.until.After(fc.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- fc.now
+	}
+	fc.waiters = waiters
 }
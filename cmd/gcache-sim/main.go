@@ -0,0 +1,202 @@
+// Command gcache-sim replays an access trace against the eviction policies of gcache
+// and reports the hit ratio, the byte hit ratio and the throughput of each one.
+//
+// Every request is a Get, a miss is followed by a Set of the key. Traces are read from
+// a file (or stdin with -trace -) in one of these formats:
+//
+//	plain  one key per line, optionally followed by the size of its value
+//	lirs   one block number per line
+//	arc    "start count ignored request" per line, the format of the ARC traces
+//	gcache the traces written by CacheBuilder.TraceRecorder
+//
+// Without a trace a Zipf distributed trace is generated.
+//
+// Usage:
+//
+//	gcache-sim -trace P8.lis -format arc -sizes 1000,10000,100000
+//	gcache-sim -zipf 1000000 -zipf-keys 100000 -zipf-s 1.1 -policies lru,arc,tinylfu -csv
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bluele/gcache"
+)
+
+// allPolicies lists the built-in policies in the order they are reported.
+var allPolicies = []string{
+	gcache.TYPE_SIMPLE,
+	gcache.TYPE_LRU,
+	gcache.TYPE_LFU,
+	gcache.TYPE_ARC,
+	gcache.TYPE_TINYLFU,
+	gcache.TYPE_2Q,
+	gcache.TYPE_SLRU,
+	gcache.TYPE_SIEVE,
+	gcache.TYPE_S3FIFO,
+}
+
+func main() {
+	var (
+		tracePath = flag.String("trace", "", "trace file to replay, - reads stdin")
+		format    = flag.String("format", "plain", "trace format: plain, lirs, arc or gcache")
+		policies  = flag.String("policies", strings.Join(allPolicies, ","), "comma separated policies to simulate")
+		sizes     = flag.String("sizes", "100,1000,10000", "comma separated cache sizes")
+		zipfN     = flag.Int("zipf", 1000000, "number of requests to generate when no trace is given")
+		zipfKeys  = flag.Int("zipf-keys", 100000, "number of distinct keys of the generated trace")
+		zipfS     = flag.Float64("zipf-s", 1.1, "exponent of the generated trace, must be > 1")
+		zipfSize  = flag.Int64("zipf-max-size", 1, "maximum value size of the generated trace")
+		seed      = flag.Int64("seed", 1, "seed of the generated trace")
+		asCSV     = flag.Bool("csv", false, "print the results as CSV instead of a table")
+	)
+	flag.Parse()
+
+	if err := run(os.Stdout, config{
+		tracePath: *tracePath,
+		format:    *format,
+		policies:  *policies,
+		sizes:     *sizes,
+		zipfN:     *zipfN,
+		zipfKeys:  *zipfKeys,
+		zipfS:     *zipfS,
+		zipfSize:  *zipfSize,
+		seed:      *seed,
+		csv:       *asCSV,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "gcache-sim:", err)
+		os.Exit(1)
+	}
+}
+
+type config struct {
+	tracePath string
+	format    string
+	policies  string
+	sizes     string
+	zipfN     int
+	zipfKeys  int
+	zipfS     float64
+	zipfSize  int64
+	seed      int64
+	csv       bool
+}
+
+func run(out io.Writer, cfg config) error {
+	policies, err := parsePolicies(cfg.policies)
+	if err != nil {
+		return err
+	}
+	sizes, err := parseSizes(cfg.sizes)
+	if err != nil {
+		return err
+	}
+	trace, err := loadTrace(cfg)
+	if err != nil {
+		return err
+	}
+
+	var results []result
+	for _, size := range sizes {
+		for _, policy := range policies {
+			results = append(results, simulate(policy, size, trace))
+		}
+	}
+	if cfg.csv {
+		return writeCSV(out, results)
+	}
+	return writeTable(out, results)
+}
+
+func loadTrace(cfg config) ([]request, error) {
+	switch cfg.tracePath {
+	case "":
+		return zipfTrace(cfg.zipfN, cfg.zipfKeys, cfg.zipfS, cfg.zipfSize, cfg.seed)
+	case "-":
+		return readTrace(os.Stdin, cfg.format)
+	}
+	f, err := os.Open(cfg.tracePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readTrace(f, cfg.format)
+}
+
+func parsePolicies(s string) ([]string, error) {
+	var policies []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		known := false
+		for _, k := range allPolicies {
+			if p == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown policy %q, expected one of %s", p, strings.Join(allPolicies, ", "))
+		}
+		policies = append(policies, p)
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no policy given")
+	}
+	return policies, nil
+}
+
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		size, err := strconv.Atoi(f)
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid cache size %q", f)
+		}
+		sizes = append(sizes, size)
+	}
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no cache size given")
+	}
+	return sizes, nil
+}
+
+func writeTable(out io.Writer, results []result) error {
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "policy\tsize\trequests\thit ratio\tbyte hit ratio\tops/s\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.4f\t%.4f\t%.0f\t\n",
+			r.policy, r.size, r.requests, r.hitRatio(), r.byteHitRatio(), r.throughput())
+	}
+	return tw.Flush()
+}
+
+func writeCSV(out io.Writer, results []result) error {
+	cw := csv.NewWriter(out)
+	cw.Write([]string{"policy", "size", "requests", "hits", "hit_ratio", "byte_hit_ratio", "ops_per_sec"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.policy,
+			strconv.Itoa(r.size),
+			strconv.Itoa(r.requests),
+			strconv.Itoa(r.hits),
+			strconv.FormatFloat(r.hitRatio(), 'f', 6, 64),
+			strconv.FormatFloat(r.byteHitRatio(), 'f', 6, 64),
+			strconv.FormatFloat(r.throughput(), 'f', 0, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bluele/gcache"
+)
+
+// result is the outcome of replaying a trace against one policy and size.
+type result struct {
+	policy   string
+	size     int
+	requests int
+	hits     int
+	bytes    int64
+	hitBytes int64
+	duration time.Duration
+}
+
+func (r result) hitRatio() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.requests)
+}
+
+func (r result) byteHitRatio() float64 {
+	if r.bytes == 0 {
+		return 0
+	}
+	return float64(r.hitBytes) / float64(r.bytes)
+}
+
+// throughput returns the number of requests per second.
+func (r result) throughput() float64 {
+	if r.duration <= 0 {
+		return 0
+	}
+	return float64(r.requests) / r.duration.Seconds()
+}
+
+// simulate replays trace against a cache of the given policy and size.
+// A miss is followed by a Set, like a cache in front of a slower store.
+func simulate(policy string, size int, trace []request) result {
+	gc := gcache.New(size).EvictType(policy).Build()
+	res := result{policy: policy, size: size, requests: len(trace)}
+	start := time.Now()
+	for _, req := range trace {
+		res.bytes += req.size
+		if _, err := gc.Get(req.key); err == nil {
+			res.hits++
+			res.hitBytes += req.size
+			continue
+		}
+		gc.Set(req.key, req.size)
+	}
+	res.duration = time.Since(start)
+	return res
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/bluele/gcache"
+)
+
+func TestReadTracePlain(t *testing.T) {
+	trace, err := readTrace(strings.NewReader("a 10\n\n# comment\nb\na 10\n"), "plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(trace))
+	}
+	if trace[0].key != "a" || trace[0].size != 10 || trace[1].key != "b" || trace[1].size != 1 {
+		t.Errorf("unexpected trace %v", trace)
+	}
+	if _, err := readTrace(strings.NewReader("a x\n"), "plain"); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+func TestReadTraceLIRS(t *testing.T) {
+	trace, err := readTrace(strings.NewReader("5\n7\n5\n*\n"), "lirs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) != 3 || trace[0].key != int64(5) || trace[2].key != int64(5) {
+		t.Errorf("unexpected trace %v", trace)
+	}
+}
+
+func TestReadTraceARC(t *testing.T) {
+	trace, err := readTrace(strings.NewReader("100 3 0 1\n7 1 0 2\n"), "arc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int64{100, 101, 102, 7}
+	if len(trace) != len(expected) {
+		t.Fatalf("expected %d requests, got %d", len(expected), len(trace))
+	}
+	for i, k := range expected {
+		if trace[i].key != k {
+			t.Errorf("request %d: expected key %d, got %v", i, k, trace[i].key)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	trace := []request{{"a", 1}, {"b", 3}, {"a", 1}, {"b", 3}, {"c", 4}}
+	for _, policy := range allPolicies {
+		r := simulate(policy, 10, trace)
+		if r.hits != 2 {
+			t.Errorf("%s: expected 2 hits, got %d", policy, r.hits)
+		}
+		if r.bytes != 12 || r.hitBytes != 4 {
+			t.Errorf("%s: expected 4 of 12 bytes hit, got %d of %d", policy, r.hitBytes, r.bytes)
+		}
+	}
+}
+
+func TestRunCSV(t *testing.T) {
+	var out bytes.Buffer
+	err := run(&out, config{
+		policies: "lru,arc",
+		sizes:    "10,100",
+		zipfN:    1000,
+		zipfKeys: 200,
+		zipfS:    1.1,
+		zipfSize: 8,
+		seed:     1,
+		csv:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("expected a header and 4 rows, got %d records", len(records))
+	}
+	if records[1][0] != "lru" || records[1][1] != "10" || records[4][0] != "arc" || records[4][1] != "100" {
+		t.Errorf("unexpected rows %v", records[1:])
+	}
+}
+
+func TestRunRejectsUnknownPolicy(t *testing.T) {
+	if err := run(&bytes.Buffer{}, config{policies: "mru", sizes: "10"}); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestReadTraceRecorded(t *testing.T) {
+	var buf bytes.Buffer
+	gc := gcache.New(10).LRU().
+		TraceRecorder(&buf).
+		TraceValueSize(func(k, v interface{}) int64 { return int64(len(v.(string))) }).
+		Build()
+	gc.Get("a")
+	gc.Set("a", "abcd")
+	gc.Get("a")
+	gc.Remove("a")
+	gc.GetIFPresent("b")
+	gc.Close()
+
+	trace, err := readTrace(&buf, "gcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trace) != 3 {
+		t.Fatalf("expected 3 lookups, got %d", len(trace))
+	}
+	if trace[0].key != trace[1].key || trace[0].size != 4 || trace[1].size != 4 || trace[2].size != 1 {
+		t.Errorf("unexpected trace %v", trace)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/bluele/gcache"
+)
+
+// request is one access of a trace, size is used for the byte hit ratio.
+type request struct {
+	key  interface{}
+	size int64
+}
+
+// readTrace parses a trace in one of the supported formats:
+//
+//	plain  one key per line, optionally followed by the size of its value
+//	lirs   one block number per line, as used by the LIRS traces
+//	arc    "start count ignored request" per line, as used by the ARC traces;
+//	       it requests the blocks start to start+count-1
+//	gcache the binary traces written by CacheBuilder.TraceRecorder
+func readTrace(r io.Reader, format string) ([]request, error) {
+	var parse func(fields []string) ([]request, error)
+	switch format {
+	case "gcache":
+		return readRecorded(r)
+	case "plain":
+		parse = parsePlain
+	case "lirs":
+		parse = parseLIRS
+	case "arc":
+		parse = parseARC
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", format)
+	}
+
+	var trace []request
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		reqs, err := parse(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		trace = append(trace, reqs...)
+	}
+	return trace, sc.Err()
+}
+
+func parsePlain(fields []string) ([]request, error) {
+	req := request{key: fields[0], size: 1}
+	if len(fields) > 1 {
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid size %q", fields[1])
+		}
+		req.size = size
+	}
+	return []request{req}, nil
+}
+
+func parseLIRS(fields []string) ([]request, error) {
+	// some LIRS traces mark their end with a '*'
+	if fields[0] == "*" {
+		return nil, nil
+	}
+	block, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block %q", fields[0])
+	}
+	return []request{{key: block, size: 1}}, nil
+}
+
+func parseARC(fields []string) ([]request, error) {
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("expected start and count, got %q", strings.Join(fields, " "))
+	}
+	start, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", fields[0])
+	}
+	count, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid count %q", fields[1])
+	}
+	reqs := make([]request, count)
+	for i := range reqs {
+		reqs[i] = request{key: start + int64(i), size: 1}
+	}
+	return reqs, nil
+}
+
+// readRecorded turns the lookups of a recorded trace into requests, Set and Remove records
+// are skipped since the simulation sets every missed key. A request gets the last size
+// recorded for its key, lookups which missed have no size of their own.
+func readRecorded(r io.Reader) ([]request, error) {
+	tr, err := gcache.NewTraceReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var trace []request
+	sizes := map[uint64]int64{}
+	for {
+		rec, err := tr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Size > 0 {
+			sizes[rec.KeyHash] = rec.Size
+		}
+		if rec.Op == gcache.TraceGet || rec.Op == gcache.TraceGetIFPresent {
+			trace = append(trace, request{key: rec.KeyHash})
+		}
+	}
+	for i := range trace {
+		size, ok := sizes[trace[i].key.(uint64)]
+		if !ok {
+			size = 1
+		}
+		trace[i].size = size
+	}
+	return trace, nil
+}
+
+// zipfTrace generates n requests over keys keys, key k is requested with a probability
+// proportional to 1/(k+1)^s. Every key has a fixed size between 1 and maxSize.
+func zipfTrace(n, keys int, s float64, maxSize int64, seed int64) ([]request, error) {
+	if s <= 1 {
+		return nil, fmt.Errorf("zipf exponent must be > 1, got %v", s)
+	}
+	if keys < 1 || maxSize < 1 {
+		return nil, fmt.Errorf("zipf keys and max size must be positive")
+	}
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, s, 1, uint64(keys-1))
+	trace := make([]request, n)
+	for i := range trace {
+		k := z.Uint64()
+		trace[i] = request{key: int64(k), size: 1 + int64(k*2654435761%uint64(maxSize))}
+	}
+	return trace, nil
+}
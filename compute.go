@@ -0,0 +1,108 @@
+package gcache
+
+import "reflect"
+
+// ComputeFunc returns the new value of a key from its current one, exists tells whether the key is stored.
+// If remove is true the key is removed instead of set.
+type ComputeFunc func(old interface{}, exists bool) (newValue interface{}, remove bool)
+
+// lockedCache is implemented by every cache type for the compute operations,
+// its methods are called with the cache lock held.
+type lockedCache interface {
+	// peek returns the stored value of key without counting a lookup, an expired item is removed.
+	peek(key interface{}) (interface{}, bool)
+	// put stores value like Set.
+	put(key, value interface{}) error
+	remove(key interface{}, cause removalCause) bool
+}
+
+type updateOp int
+
+const (
+	updateKeep updateOp = iota
+	updateSet
+	updateRemove
+)
+
+// update runs f on the value of key with the cache lock held, then stores or removes the value f returns.
+// It returns the value of key before the update, the callbacks run like they do for Set and Remove.
+func (c *baseCache) update(lc lockedCache, key interface{}, f func(old interface{}, exists bool) (interface{}, updateOp)) (interface{}, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, exists := lc.peek(key)
+	if exists && c.deserializeFunc != nil {
+		var err error
+		if old, err = c.deserializeFunc(key, old); err != nil {
+			return nil, false, err
+		}
+	}
+	v, op := f(old, exists)
+	switch op {
+	case updateSet:
+		c.trace(TraceSet, key, 0, v)
+		c.forgetError(key)
+		return old, exists, lc.put(key, v)
+	case updateRemove:
+		c.traceRemove(key, lc.remove(key, causeRemoved))
+		c.forgetError(key)
+	}
+	return old, exists, nil
+}
+
+// getOrSet returns the value of key if it is stored, otherwise it sets value.
+// loaded tells whether the value was stored before.
+func (c *baseCache) getOrSet(lc lockedCache, key, value interface{}) (actual interface{}, loaded bool, err error) {
+	old, exists, err := c.update(lc, key, func(old interface{}, exists bool) (interface{}, updateOp) {
+		if exists {
+			return nil, updateKeep
+		}
+		return value, updateSet
+	})
+	if exists {
+		return old, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, false, nil
+}
+
+// setIfAbsent sets value if key is not stored, and reports whether it did.
+func (c *baseCache) setIfAbsent(lc lockedCache, key, value interface{}) (bool, error) {
+	_, exists, err := c.getOrSet(lc, key, value)
+	return !exists && err == nil, err
+}
+
+// compute replaces the value of key with the result of f and returns it, nil if f removed the key.
+func (c *baseCache) compute(lc lockedCache, key interface{}, f ComputeFunc) (interface{}, error) {
+	var value interface{}
+	_, _, err := c.update(lc, key, func(old interface{}, exists bool) (interface{}, updateOp) {
+		v, remove := f(old, exists)
+		if remove {
+			return nil, updateRemove
+		}
+		value = v
+		return v, updateSet
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// compareAndSwap sets new if the value of key equals old, and reports whether it did.
+// == panics for slices, maps and funcs, so such an old value is rejected with NotComparableErr.
+func (c *baseCache) compareAndSwap(lc lockedCache, key, old, new interface{}) (bool, error) {
+	if old != nil && !reflect.ValueOf(old).Comparable() {
+		return false, NotComparableErr
+	}
+	swapped := false
+	_, _, err := c.update(lc, key, func(cur interface{}, exists bool) (interface{}, updateOp) {
+		if !exists || cur != old {
+			return nil, updateKeep
+		}
+		swapped = true
+		return new, updateSet
+	})
+	return swapped && err == nil, err
+}
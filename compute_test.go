@@ -0,0 +1,215 @@
+package gcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetOrSet(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		added := 0
+		gc := New(10).
+			EvictType(tp).
+			AddedFunc(func(key, value interface{}) {
+				added++
+			}).
+			Build()
+
+		v, loaded, err := gc.GetOrSet("a", 1)
+		if v != 1 || loaded || err != nil {
+			t.Errorf("%s: expected 1 to be set, got %v, %v, %v", tp, v, loaded, err)
+		}
+		v, loaded, err = gc.GetOrSet("a", 2)
+		if v != 1 || !loaded || err != nil {
+			t.Errorf("%s: expected the stored 1, got %v, %v, %v", tp, v, loaded, err)
+		}
+		if ok, err := gc.SetIfAbsent("a", 3); ok || err != nil {
+			t.Errorf("%s: expected a to be kept, got %v, %v", tp, ok, err)
+		}
+		if ok, err := gc.SetIfAbsent("b", 3); !ok || err != nil {
+			t.Errorf("%s: expected b to be set, got %v, %v", tp, ok, err)
+		}
+		if added != 2 {
+			t.Errorf("%s: expected 2 added callbacks, got %d", tp, added)
+		}
+		if v, _ := gc.Get("a"); v != 1 {
+			t.Errorf("%s: expected 1, got %v", tp, v)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var evicted []interface{}
+		gc := New(10).
+			EvictType(tp).
+			EvictedFunc(func(key, value interface{}) {
+				evicted = append(evicted, value)
+			}).
+			Build()
+		incr := func(old interface{}, exists bool) (interface{}, bool) {
+			if !exists {
+				return 1, false
+			}
+			return old.(int) + 1, false
+		}
+
+		gc.Compute("a", incr)
+		if v, err := gc.Compute("a", incr); v != 2 || err != nil {
+			t.Errorf("%s: expected 2, got %v, %v", tp, v, err)
+		}
+		v, err := gc.Compute("a", func(old interface{}, exists bool) (interface{}, bool) {
+			return nil, true
+		})
+		if v != nil || err != nil {
+			t.Errorf("%s: expected a removal, got %v, %v", tp, v, err)
+		}
+		if _, err := gc.GetIFPresent("a"); err != KeyNotFoundError {
+			t.Errorf("%s: expected a to be removed, got %v", tp, err)
+		}
+		if len(evicted) != 1 || evicted[0] != 2 {
+			t.Errorf("%s: expected an evicted callback for 2, got %v", tp, evicted)
+		}
+		if st := gc.Stats(); st.RemovalCount != 1 {
+			t.Errorf("%s: expected 1 removal, got %d", tp, st.RemovalCount)
+		}
+
+		gc.Compute("b", func(old interface{}, exists bool) (interface{}, bool) {
+			return nil, true
+		})
+		if gc.Len() != 0 {
+			t.Errorf("%s: expected an empty cache, got %v", tp, gc.Keys())
+		}
+	}
+}
+
+func TestComputeConcurrent(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_TINYLFU, TYPE_S3FIFO} {
+		gc := New(10).EvictType(tp).Build()
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				gc.Compute("n", func(old interface{}, exists bool) (interface{}, bool) {
+					if !exists {
+						return 1, false
+					}
+					return old.(int) + 1, false
+				})
+			}()
+		}
+		wg.Wait()
+		if v, _ := gc.Get("n"); v != 50 {
+			t.Errorf("%s: expected 50, got %v", tp, v)
+		}
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		gc := New(10).EvictType(tp).Build()
+		if ok, _ := gc.CompareAndSwap("a", nil, 1); ok {
+			t.Errorf("%s: expected no swap of a missing key", tp)
+		}
+		gc.Set("a", 1)
+		if ok, _ := gc.CompareAndSwap("a", 2, 3); ok {
+			t.Errorf("%s: expected no swap of a different value", tp)
+		}
+		if ok, err := gc.CompareAndSwap("a", 1, 3); !ok || err != nil {
+			t.Errorf("%s: expected a swap, got %v, %v", tp, ok, err)
+		}
+		if v, _ := gc.Get("a"); v != 3 {
+			t.Errorf("%s: expected 3, got %v", tp, v)
+		}
+	}
+}
+
+func TestCompareAndSwapNotComparable(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		gc := New(10).EvictType(tp).Build()
+		gc.Set("a", []byte("x"))
+		if ok, err := gc.CompareAndSwap("a", []byte("x"), []byte("y")); ok || err != NotComparableErr {
+			t.Errorf("%s: expected NotComparableErr, got %v, %v", tp, ok, err)
+		}
+		// the lock was released
+		if v, err := gc.Get("a"); string(v.([]byte)) != "x" || err != nil {
+			t.Errorf("%s: expected x, got %v, %v", tp, v, err)
+		}
+	}
+	tc := NewTyped[string, []byte](10).LRU().Build()
+	tc.Set("a", []byte("x"))
+	if _, err := tc.CompareAndSwap("a", []byte("x"), nil); err != NotComparableErr {
+		t.Errorf("expected NotComparableErr, got %v", err)
+	}
+}
+
+func TestComputeExpired(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		gc := New(10).EvictType(tp).Clock(clock).Build()
+		gc.SetWithExpire("a", 1, time.Second)
+		clock.Advance(2 * time.Second)
+		if v, loaded, _ := gc.GetOrSet("a", 2); v != 2 || loaded {
+			t.Errorf("%s: expected the expired value to be replaced, got %v, %v", tp, v, loaded)
+		}
+		if st := gc.Stats(); st.ExpirationCount != 1 {
+			t.Errorf("%s: expected 1 expiration, got %d", tp, st.ExpirationCount)
+		}
+	}
+}
+
+func TestComputeSerialized(t *testing.T) {
+	gc := New(10).
+		LRU().
+		SerializeFunc(func(key, value interface{}) (interface{}, error) {
+			return value.(int) * 10, nil
+		}).
+		DeserializeFunc(func(key, value interface{}) (interface{}, error) {
+			return value.(int) / 10, nil
+		}).
+		Build()
+	gc.Set("a", 1)
+	if ok, _ := gc.CompareAndSwap("a", 1, 2); !ok {
+		t.Error("expected the deserialized value to be compared")
+	}
+	if v, _ := gc.Get("a"); v != 2 {
+		t.Errorf("expected 2, got %v", v)
+	}
+}
+
+func TestShardedCompute(t *testing.T) {
+	gc := New(4).LRU().Shards(2).Build()
+	for i := 0; i < 10; i++ {
+		gc.SetIfAbsent(i, i)
+	}
+	if gc.Len() > 4 {
+		t.Errorf("expected at most 4 items, got %d", gc.Len())
+	}
+	v, err := gc.Compute(100, func(old interface{}, exists bool) (interface{}, bool) {
+		return "new", false
+	})
+	if v != "new" || err != nil {
+		t.Errorf("expected new, got %v, %v", v, err)
+	}
+}
+
+func TestTypedCompute(t *testing.T) {
+	tc := NewTyped[string, int](10).LRU().Build()
+	if v, loaded, _ := tc.GetOrSet("a", 1); v != 1 || loaded {
+		t.Errorf("expected 1 to be set, got %v, %v", v, loaded)
+	}
+	v, err := tc.Compute("a", func(old int, exists bool) (int, bool) {
+		return old + 1, false
+	})
+	if v != 2 || err != nil {
+		t.Errorf("expected 2, got %v, %v", v, err)
+	}
+	if ok, _ := tc.CompareAndSwap("a", 2, 5); !ok {
+		t.Error("expected a swap")
+	}
+	if ok, _ := tc.SetIfAbsent("a", 7); ok {
+		t.Error("expected a to be kept")
+	}
+}
@@ -0,0 +1,87 @@
+package gcache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap indexes keys by their expiration time, the key which expires first is on top.
+// Keys without an expiration are not part of it. It is guarded by the cache lock.
+type expiryHeap struct {
+	entries []expiryEntry
+	index   map[interface{}]int
+}
+
+type expiryEntry struct {
+	key interface{}
+	at  time.Time
+}
+
+func (h *expiryHeap) Len() int { return len(h.entries) }
+
+func (h *expiryHeap) Less(i, j int) bool { return h.entries[i].at.Before(h.entries[j].at) }
+
+func (h *expiryHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(expiryEntry)
+	h.index[e.key] = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	n := len(h.entries) - 1
+	e := h.entries[n]
+	h.entries[n] = expiryEntry{}
+	h.entries = h.entries[:n]
+	delete(h.index, e.key)
+	return e
+}
+
+// set indexes key under at, a nil at removes key from the index.
+func (h *expiryHeap) set(key interface{}, at *time.Time) {
+	if at == nil {
+		h.remove(key)
+		return
+	}
+	if h.index == nil {
+		h.index = make(map[interface{}]int)
+	}
+	if i, ok := h.index[key]; ok {
+		h.entries[i].at = *at
+		heap.Fix(h, i)
+		return
+	}
+	heap.Push(h, expiryEntry{key: key, at: *at})
+}
+
+func (h *expiryHeap) remove(key interface{}) {
+	if i, ok := h.index[key]; ok {
+		heap.Remove(h, i)
+	}
+}
+
+// peek returns the key which expires first.
+func (h *expiryHeap) peek() (interface{}, time.Time, bool) {
+	if len(h.entries) == 0 {
+		return nil, time.Time{}, false
+	}
+	return h.entries[0].key, h.entries[0].at, true
+}
+
+// popExpired removes and returns the key which expires first if it is expired at now.
+func (h *expiryHeap) popExpired(now time.Time) (interface{}, bool) {
+	if len(h.entries) == 0 || !h.entries[0].at.Before(now) {
+		return nil, false
+	}
+	return heap.Pop(h).(expiryEntry).key, true
+}
+
+func (h *expiryHeap) reset() {
+	h.entries = nil
+	h.index = nil
+}
@@ -0,0 +1,249 @@
+package gcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExpiryHeap(t *testing.T) {
+	var h expiryHeap
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	for i := 0; i < 10; i++ {
+		h.set(i, at(time.Duration(10-i)*time.Second))
+	}
+	h.set(3, at(100*time.Second))
+	h.remove(9)
+	h.set(8, nil)
+
+	if key, _, _ := h.peek(); key != 7 {
+		t.Errorf("%v != %v", key, 7)
+	}
+	var keys []interface{}
+	for key, ok := h.popExpired(now.Add(8 * time.Second)); ok; key, ok = h.popExpired(now.Add(8 * time.Second)) {
+		keys = append(keys, key)
+	}
+	if fmt.Sprint(keys) != "[7 6 5 4]" {
+		t.Errorf("unexpected expired keys %v", keys)
+	}
+	if h.Len() != 4 || len(h.index) != 4 {
+		t.Errorf("%v != %v", h.Len(), 4)
+	}
+	h.reset()
+	if _, _, ok := h.peek(); ok {
+		t.Error("the heap should be empty after reset")
+	}
+}
+
+func TestExpiryIndexFollowsItems(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		gc := New(5).EvictType(tp).Clock(clock).Build()
+		for i := 0; i < 20; i++ {
+			gc.SetWithExpire(i, i, time.Duration(i+1)*time.Second)
+			if i%3 == 0 {
+				gc.Remove(i)
+			}
+			if i%4 == 0 {
+				gc.Set(i, i)
+			}
+		}
+		clock.Advance(12 * time.Second)
+		gc.Keys()
+		if n, m := expiryLen(gc), expiringItems(gc); n != m {
+			t.Errorf("%s: the index has %v keys, the cache has %v expiring items", tp, n, m)
+		}
+		gc.Purge()
+		if n := expiryLen(gc); n != 0 {
+			t.Errorf("%s: %v != %v", tp, n, 0)
+		}
+	}
+}
+
+func expiryLen(gc Cache) int {
+	switch c := gc.(type) {
+	case *SimpleCache:
+		return c.expiries.Len()
+	case *LRUCache:
+		return c.expiries.Len()
+	case *LFUCache:
+		return c.expiries.Len()
+	case *ARC:
+		return c.expiries.Len()
+	case *TinyLFUCache:
+		return c.expiries.Len()
+	case *TwoQueueCache:
+		return c.expiries.Len()
+	case *SLRUCache:
+		return c.expiries.Len()
+	case *SieveCache:
+		return c.expiries.Len()
+	case *S3FIFOCache:
+		return c.expiries.Len()
+	}
+	panic("unknown cache")
+}
+
+func expiringItems(gc Cache) int {
+	n := 0
+	switch c := gc.(type) {
+	case *SimpleCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *LRUCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *LFUCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *ARC:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *TinyLFUCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *TwoQueueCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *SLRUCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *SieveCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	case *S3FIFOCache:
+		for _, it := range c.items {
+			if it.expiration != nil {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestSimpleEvictClosestToExpiry(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(3).Simple().Clock(clock).Build()
+	gc.Set("forever", 0)
+	gc.SetWithExpire("late", 1, time.Hour)
+	gc.SetWithExpire("soon", 2, time.Minute)
+	if err := gc.Set("new", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gc.GetIFPresent("soon"); err != KeyNotFoundError {
+		t.Error("the item closest to expiry should be evicted")
+	}
+	for _, key := range []string{"forever", "late", "new"} {
+		if _, err := gc.GetIFPresent(key); err != nil {
+			t.Errorf("%v: %v", key, err)
+		}
+	}
+}
+
+func TestLRUEvictExpiredFirst(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(2).LRU().Clock(clock).Build()
+	gc.Set("old", 0)
+	gc.SetWithExpire("expired", 1, time.Second)
+	clock.Advance(time.Minute)
+	gc.Set("new", 2)
+	if _, err := gc.GetIFPresent("old"); err != nil {
+		t.Error("an expired item should be evicted before the least recently used one")
+	}
+}
+
+func TestOrderedEvictFrontBeforeUnexpired(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(3).Clock(clock).BuildOrderedCache()
+	oc := c.(*SimpleOrderedCache)
+	c.EnQueue(1, "a")
+	c.EnQueue(2, "b")
+	c.EnQueue(3, "c")
+	oc.mu.Lock()
+	t2 := clock.Now().Add(time.Minute)
+	oc.items[2].expiration = &t2
+	oc.expiries.set(2, &t2)
+	oc.mu.Unlock()
+
+	// 2 expires soon but is not expired, the queue order decides
+	if err := c.EnQueue(4, "d"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fmt.Sprint(c.OrderedKeys()); keys != "[2 3 4]" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	clock.Advance(2 * time.Minute)
+	if err := c.EnQueue(5, "e"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fmt.Sprint(c.OrderedKeys()); keys != "[3 4 5]" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+func TestOrderedEvictExpiredFuncFirst(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(3).Clock(clock).ExpiredFunc(func(key interface{}) bool {
+		return key == 3
+	}).BuildOrderedCache()
+	c.EnQueue(1, "a")
+	c.EnQueue(2, "b")
+	c.EnQueue(3, "c")
+
+	// 3 is flagged, it goes before 1 at the front of the queue
+	if err := c.EnQueue(4, "d"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fmt.Sprint(c.OrderedKeys()); keys != "[1 2 4]" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if n := c.Stats().ExpirationCount; n != 1 {
+		t.Errorf("%v != %v", n, 1)
+	}
+}
+
+func TestOrderedLenDropsExpired(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(10).Clock(clock).Expiration(time.Minute).BuildOrderedCache()
+	c.EnQueue(1, "a")
+	clock.Advance(30 * time.Second)
+	c.EnQueue(2, "b")
+	clock.Advance(45 * time.Second)
+	if n := c.QueueLen(); n != 2 {
+		t.Errorf("%v != %v", n, 2)
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("%v != %v", n, 1)
+	}
+	if n := c.QueueLen(); n != 1 {
+		t.Errorf("%v != %v", n, 1)
+	}
+}
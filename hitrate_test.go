@@ -0,0 +1,64 @@
+package gcache
+
+import (
+	"math/rand"
+)
+
+// hotSetWithScans returns a trace in which accesses to a small hot set are
+// interleaved with long scans over keys which are never seen again.
+func hotSetWithScans(n int) []int {
+	r := rand.New(rand.NewSource(1))
+	trace := make([]int, 0, n)
+	scan := 1000
+	for len(trace) < n {
+		for i := 0; i < 200; i++ {
+			trace = append(trace, r.Intn(100))
+		}
+		for i := 0; i < 300; i++ {
+			trace = append(trace, scan)
+			scan++
+		}
+	}
+	return trace[:n]
+}
+
+// replayHitRate looks up every key of trace, sets the missing ones and returns the hit rate.
+func replayHitRate(gc Cache, trace []int) float64 {
+	for _, k := range trace {
+		if _, err := gc.Get(k); err == KeyNotFoundError {
+			gc.Set(k, k)
+		}
+	}
+	return gc.HitRate()
+}
+
+// zipfWithScans returns a trace of 1000 keys with a zipf distribution,
+// interrupted now and then by a scan of 200 keys which are never seen again.
+func zipfWithScans(n int) []int {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.1, 1, 999)
+	trace := make([]int, 0, n)
+	scan := 100000
+	for len(trace) < n {
+		if r.Intn(1000) == 0 {
+			for i := 0; i < 200; i++ {
+				trace = append(trace, scan)
+				scan++
+			}
+			continue
+		}
+		trace = append(trace, int(z.Uint64()))
+	}
+	return trace[:n]
+}
+
+// zipfTrace returns a trace of 10000 keys with a zipf distribution.
+func zipfTrace(n int) []int {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.01, 1, 9999)
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(z.Uint64())
+	}
+	return trace
+}
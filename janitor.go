@@ -0,0 +1,53 @@
+package gcache
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor removes expired items in the background until the cache is closed.
+type janitor struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// startJanitor runs sweep every interval of the cache clock, it does nothing if interval <= 0.
+func (c *baseCache) startJanitor(interval time.Duration, sweep func()) {
+	if interval <= 0 {
+		return
+	}
+	j := &janitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.janitor = j
+	go func() {
+		defer close(j.done)
+		for {
+			tick, stop := after(c.clock, interval)
+			select {
+			case <-tick:
+				sweep()
+			case <-j.stop:
+				stop()
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background janitor and waits for a running sweep to finish,
+// then flushes the TraceRecorder. The cache itself stays usable, it is safe to call Close more than once.
+func (c *baseCache) Close() error {
+	if j := c.janitor; j != nil {
+		j.once.Do(func() {
+			close(j.stop)
+		})
+		<-j.done
+	}
+	if c.recorder != nil {
+		return c.recorder.flush()
+	}
+	return nil
+}
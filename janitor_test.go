@@ -0,0 +1,110 @@
+package gcache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor advances clock until cond holds or a second of real time has passed.
+func waitFor(clock FakeClock, step time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		clock.Advance(step)
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestJanitorRemovesExpiredItems(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		var evicted int64
+		gc := New(10).
+			EvictType(tp).
+			Clock(clock).
+			CleanupInterval(time.Minute).
+			EvictedFunc(func(key, value interface{}) {
+				atomic.AddInt64(&evicted, 1)
+			}).
+			Build()
+		gc.SetWithExpire("short", 1, time.Second)
+		gc.SetWithExpire("long", 2, time.Hour)
+		gc.Set("forever", 3)
+
+		if !waitFor(clock, time.Minute, func() bool { return atomic.LoadInt64(&evicted) == 1 }) {
+			t.Errorf("%s: the expired item should be evicted by the janitor, evicted %v", tp, atomic.LoadInt64(&evicted))
+		}
+		if err := gc.Close(); err != nil {
+			t.Errorf("%s: %v", tp, err)
+		}
+		if err := gc.Close(); err != nil {
+			t.Errorf("%s: second Close: %v", tp, err)
+		}
+
+		// nothing is swept after Close
+		clock.Advance(2 * time.Hour)
+		time.Sleep(5 * time.Millisecond)
+		if n := atomic.LoadInt64(&evicted); n != 1 {
+			t.Errorf("%s: %v != %v", tp, n, 1)
+		}
+		if _, err := gc.GetIFPresent("forever"); err != nil {
+			t.Errorf("%s: cache should stay usable after Close: %v", tp, err)
+		}
+	}
+}
+
+func TestJanitorCloseStopsTimer(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(10).Clock(clock).CleanupInterval(time.Minute).Build()
+	fc := clock.(*fakeclock)
+	waiters := func() int {
+		fc.mutex.RLock()
+		defer fc.mutex.RUnlock()
+		return len(fc.waiters)
+	}
+	if !waitFor(clock, 0, func() bool { return waiters() == 1 }) {
+		t.Fatalf("expected the janitor to wait, got %v waiters", waiters())
+	}
+	gc.Close()
+	if n := waiters(); n != 0 {
+		t.Errorf("%v != %v", n, 0)
+	}
+}
+
+func TestJanitorOrderedCache(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(10).Clock(clock).CleanupInterval(time.Minute).BuildOrderedCache()
+	defer c.Close()
+	c.EnQueue(1, "a")
+	c.EnQueue(2, "b")
+	oc := c.(*SimpleOrderedCache)
+	oc.mu.Lock()
+	t1 := clock.Now().Add(time.Second)
+	oc.items[1].expiration = &t1
+	oc.expiries.set(1, &t1)
+	oc.mu.Unlock()
+
+	removed := func() bool {
+		oc.mu.RLock()
+		defer oc.mu.RUnlock()
+		_, ok := oc.items[1]
+		return !ok
+	}
+	if !waitFor(clock, time.Minute, removed) {
+		t.Error("the expired item should be removed by the janitor")
+	}
+	if k, _, err := c.GetTop(); err != nil || k != 2 {
+		t.Errorf("unexpected top %v %v", k, err)
+	}
+}
+
+func TestCloseWithoutJanitor(t *testing.T) {
+	gc := New(10).LRU().Build()
+	if err := gc.Close(); err != nil {
+		t.Error(err)
+	}
+}
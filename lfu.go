@@ -2,318 +2,126 @@ package gcache
 
 import (
 	"container/list"
-	"time"
+	"sort"
 )
 
 // Discards the least frequently used items first.
 type LFUCache struct {
-	baseCache
-	items    map[interface{}]*lfuItem
-	freqList *list.List // list for freqEntry
+	policyCache
 }
 
 func newLFUCache(cb *CacheBuilder) *LFUCache {
 	c := &LFUCache{}
-	buildCache(&c.baseCache, cb)
-
-	c.init()
-	c.loadGroup.cache = c
+	c.setup(cb, TYPE_LFU, &lfuPolicy{})
 	return c
 }
 
-func (c *LFUCache) init() {
-	c.freqList = list.New()
-	c.items = make(map[interface{}]*lfuItem, c.size+1)
-	c.freqList.PushFront(&freqEntry{
-		freq:  0,
-		items: make(map[*lfuItem]struct{}),
-	})
+// lfuPolicy groups the keys by access frequency. The frequency list is kept
+// contiguous, every frequency from 0 to the highest one has an entry.
+type lfuPolicy struct {
+	freqList *list.List // list for freqEntry
+	elements map[interface{}]*list.Element
 }
 
-// Set a new key-value pair
-func (c *LFUCache) Set(key, value interface{}) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	_, err := c.set(key, value)
-	return err
+type freqEntry struct {
+	freq uint
+	keys map[interface{}]struct{}
 }
 
-// Set a new key-value pair with an expiration time
-func (c *LFUCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	item, err := c.set(key, value)
-	if err != nil {
-		return err
-	}
-
-	t := c.clock.Now().Add(expiration)
-	item.(*lfuItem).expiration = &t
-	return nil
+func (p *lfuPolicy) OnAdd(key interface{}) {
+	el := p.freqList.Front()
+	el.Value.(*freqEntry).keys[key] = struct{}{}
+	p.elements[key] = el
 }
 
-func (c *LFUCache) set(key, value interface{}) (interface{}, error) {
-	var err error
-	if c.serializeFunc != nil {
-		value, err = c.serializeFunc(key, value)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Check for existing item
-	item, ok := c.items[key]
-	if ok {
-		item.value = value
-	} else {
-		// Verify size not exceeded
-		if len(c.items) >= c.size {
-			c.evict(1)
-		}
-		item = &lfuItem{
-			clock:       c.clock,
-			key:         key,
-			value:       value,
-			freqElement: nil,
-		}
-		el := c.freqList.Front()
-		fe := el.Value.(*freqEntry)
-		fe.items[item] = struct{}{}
-
-		item.freqElement = el
-		c.items[key] = item
-	}
-
-	if c.expiration != nil {
-		t := c.clock.Now().Add(*c.expiration)
-		item.expiration = &t
+func (p *lfuPolicy) OnAccess(key interface{}) {
+	el, ok := p.elements[key]
+	if !ok {
+		return
 	}
+	delete(el.Value.(*freqEntry).keys, key)
+	next := p.next(el)
+	next.Value.(*freqEntry).keys[key] = struct{}{}
+	p.elements[key] = next
+}
 
-	if c.addedFunc != nil {
-		c.addedFunc(key, value)
+// next returns the entry following el, it is created if el has the highest frequency.
+func (p *lfuPolicy) next(el *list.Element) *list.Element {
+	if next := el.Next(); next != nil {
+		return next
 	}
-
-	return item, nil
+	return p.freqList.InsertAfter(&freqEntry{
+		freq: el.Value.(*freqEntry).freq + 1,
+		keys: make(map[interface{}]struct{}),
+	}, el)
 }
 
-// Get a value from cache pool using key if it exists.
-// If it dose not exists key and has LoaderFunc,
-// generate a value using `LoaderFunc` method returns value.
-func (c *LFUCache) Get(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, true)
+func (p *lfuPolicy) OnRemove(key interface{}) {
+	if el, ok := p.elements[key]; ok {
+		delete(el.Value.(*freqEntry).keys, key)
+		delete(p.elements, key)
 	}
-	return v, err
 }
 
-// Get a value from cache pool using key if it exists.
-// If it dose not exists key, returns KeyNotFoundError.
-// And send a request which refresh value for specified key if cache object has LoaderFunc.
-func (c *LFUCache) GetIFPresent(key interface{}) (interface{}, error) {
-	v, err := c.get(key, false)
-	if err == KeyNotFoundError {
-		return c.getWithLoader(key, false)
+func (p *lfuPolicy) Victim() (interface{}, bool) {
+	for el := p.freqList.Front(); el != nil; el = el.Next() {
+		for key := range el.Value.(*freqEntry).keys {
+			return key, true
+		}
 	}
-	return v, err
+	return nil, false
 }
 
-func (c *LFUCache) get(key interface{}, onLoad bool) (interface{}, error) {
-	v, err := c.getValue(key, onLoad)
-	if err != nil {
-		return nil, err
-	}
-	if c.deserializeFunc != nil {
-		return c.deserializeFunc(key, v)
-	}
-	return v, nil
+func (p *lfuPolicy) Reset() {
+	p.freqList = list.New()
+	p.freqList.PushFront(&freqEntry{
+		freq: 0,
+		keys: make(map[interface{}]struct{}),
+	})
+	p.elements = make(map[interface{}]*list.Element)
 }
 
-func (c *LFUCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
-	c.mu.Lock()
-	item, ok := c.items[key]
-	if ok {
-		if !item.IsExpired(nil) {
-			c.increment(item)
-			v := item.value
-			c.mu.Unlock()
-			if !onLoad {
-				c.stats.IncrHitCount()
-			}
-			return v, nil
+// save keeps the access frequency of every item.
+func (p *lfuPolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	entries := make([]snapshotEntry, 0, len(p.elements))
+	for el := p.freqList.Front(); el != nil; el = el.Next() {
+		fe := el.Value.(*freqEntry)
+		for key := range fe.keys {
+			e := entry(key)
+			e.freq = uint64(fe.freq)
+			entries = append(entries, e)
 		}
-		c.removeItem(item)
 	}
-	c.mu.Unlock()
-	if !onLoad {
-		c.stats.IncrMissCount()
+	return func(sw *snapshotWriter) error {
+		return sw.writeEntries(entries)
 	}
-	return nil, KeyNotFoundError
 }
 
-func (c *LFUCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderExpireFunc == nil {
-		return nil, KeyNotFoundError
-	}
-	value, _, err := c.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
-		if e != nil {
-			return nil, e
-		}
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		item, err := c.set(key, v)
-		if err != nil {
-			return nil, err
-		}
-		if expiration != nil {
-			t := c.clock.Now().Add(*expiration)
-			item.(*lfuItem).expiration = &t
-		}
-		return v, nil
-	}, isWait)
+// load restores the access frequencies. The size and the max weight are spent on
+// the most frequently used entries, the least frequently used ones are dropped.
+func (p *lfuPolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	entries, err := sr.readEntries()
 	if err != nil {
 		return nil, err
 	}
-	return value, nil
-}
-
-func (c *LFUCache) increment(item *lfuItem) {
-	currentFreqElement := item.freqElement
-	currentFreqEntry := currentFreqElement.Value.(*freqEntry)
-	nextFreq := currentFreqEntry.freq + 1
-	delete(currentFreqEntry.items, item)
-
-	nextFreqElement := currentFreqElement.Next()
-	if nextFreqElement == nil {
-		nextFreqElement = c.freqList.InsertAfter(&freqEntry{
-			freq:  nextFreq,
-			items: make(map[*lfuItem]struct{}),
-		}, currentFreqElement)
-	}
-	nextFreqElement.Value.(*freqEntry).items[item] = struct{}{}
-	item.freqElement = nextFreqElement
-}
-
-// evict removes the least frequence item from the cache.
-func (c *LFUCache) evict(count int) {
-	entry := c.freqList.Front()
-	for i := 0; i < count; {
-		if entry == nil {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].freq > entries[j].freq
+	})
+	return func(add func(e snapshotEntry) bool) {
+		if len(entries) == 0 {
 			return
-		} else {
-			for item, _ := range entry.Value.(*freqEntry).items {
-				if i >= count {
-					return
-				}
-				c.removeItem(item)
-				i++
-			}
-			entry = entry.Next()
 		}
-	}
-}
-
-// Removes the provided key from the cache.
-func (c *LFUCache) Remove(key interface{}) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	return c.remove(key)
-}
-
-func (c *LFUCache) remove(key interface{}) bool {
-	if item, ok := c.items[key]; ok {
-		c.removeItem(item)
-		return true
-	}
-	return false
-}
-
-// removeElement is used to remove a given list element from the cache
-func (c *LFUCache) removeItem(item *lfuItem) {
-	delete(c.items, item.key)
-	delete(item.freqElement.Value.(*freqEntry).items, item)
-	if c.evictedFunc != nil {
-		c.evictedFunc(item.key, item.value)
-	}
-}
-
-func (c *LFUCache) keys() []interface{} {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	keys := make([]interface{}, len(c.items))
-	var i = 0
-	for k := range c.items {
-		keys[i] = k
-		i++
-	}
-	return keys
-}
-
-// Returns a slice of the keys in the cache.
-func (c *LFUCache) Keys() []interface{} {
-	keys := []interface{}{}
-	for _, k := range c.keys() {
-		_, err := c.GetIFPresent(k)
-		if err == nil {
-			keys = append(keys, k)
-		}
-	}
-	return keys
-}
-
-// Returns all key-value pairs in the cache.
-func (c *LFUCache) GetALL() map[interface{}]interface{} {
-	m := make(map[interface{}]interface{})
-	for _, k := range c.keys() {
-		v, err := c.GetIFPresent(k)
-		if err == nil {
-			m[k] = v
+		// entries are sorted from the highest frequency, create the list up to it first
+		els := []*list.Element{p.freqList.Front()}
+		for uint64(len(els)) <= entries[0].freq {
+			els = append(els, p.next(els[len(els)-1]))
 		}
-	}
-	return m
-}
-
-// Returns the number of items in the cache.
-func (c *LFUCache) Len() int {
-	return len(c.GetALL())
-}
-
-// Completely clear the cache
-func (c *LFUCache) Purge() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.purgeVisitorFunc != nil {
-		for key, item := range c.items {
-			c.purgeVisitorFunc(key, item.value)
+		for _, e := range entries {
+			if add(e) {
+				el := els[e.freq]
+				el.Value.(*freqEntry).keys[e.key] = struct{}{}
+				p.elements[e.key] = el
+			}
 		}
-	}
-
-	c.init()
-}
-
-type freqEntry struct {
-	freq  uint
-	items map[*lfuItem]struct{}
-}
-
-type lfuItem struct {
-	clock       Clock
-	key         interface{}
-	value       interface{}
-	freqElement *list.Element
-	expiration  *time.Time
-}
-
-// returns boolean value whether this item is expired or not.
-func (it *lfuItem) IsExpired(now *time.Time) bool {
-	if it.expiration == nil {
-		return false
-	}
-	if now == nil {
-		t := it.clock.Now()
-		now = &t
-	}
-	return it.expiration.Before(*now)
+	}, nil
 }
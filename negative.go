@@ -0,0 +1,144 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NegativeCacheFunc tells whether the loader error err for key is cached by NegativeCacheTTL.
+type NegativeCacheFunc func(key interface{}, err error) bool
+
+// NegativeCacheTTL caches the errors of the loader for d, e.g. for rows which do not exist.
+// Until then Get returns the same error without calling the loader again, GetIFPresent
+// returns KeyNotFoundError. Set, Remove and Purge drop the cached error of a key.
+// By default every error is cached except timeouts and cancelled contexts, use
+// NegativeCacheFunc to pick the errors. Cached errors are counted as NegativeHitCount.
+func (cb *CacheBuilder) NegativeCacheTTL(d time.Duration) *CacheBuilder {
+	cb.negativeTTL = d
+	return cb
+}
+
+// NegativeCacheFunc sets the function which tells which loader errors are cached by NegativeCacheTTL.
+func (cb *CacheBuilder) NegativeCacheFunc(negativeCacheFunc NegativeCacheFunc) *CacheBuilder {
+	cb.negativeFunc = negativeCacheFunc
+	return cb
+}
+
+// temporaryError reports whether err is a timeout or a cancellation, which the next load may not run into.
+func temporaryError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+type negativeEntry struct {
+	err        error
+	expiration time.Time
+}
+
+// negativeCache holds the cached loader errors, at most as many as the cache holds items.
+type negativeCache struct {
+	mu      sync.Mutex
+	clock   Clock
+	ttl     time.Duration
+	size    int
+	cache   NegativeCacheFunc
+	entries map[interface{}]negativeEntry
+}
+
+func newNegativeCache(cb *CacheBuilder) *negativeCache {
+	if cb.negativeTTL <= 0 {
+		return nil
+	}
+	nc := &negativeCache{
+		clock:   cb.clock,
+		ttl:     cb.negativeTTL,
+		size:    cb.size,
+		cache:   cb.negativeFunc,
+		entries: make(map[interface{}]negativeEntry),
+	}
+	if nc.size <= 0 {
+		nc.size = DefaultMaxSize
+	}
+	if nc.cache == nil {
+		nc.cache = func(key interface{}, err error) bool {
+			return !temporaryError(err)
+		}
+	}
+	return nc
+}
+
+func (nc *negativeCache) get(key interface{}) (error, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	e, ok := nc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !nc.clock.Now().Before(e.expiration) {
+		delete(nc.entries, key)
+		return nil, false
+	}
+	return e.err, true
+}
+
+func (nc *negativeCache) set(key interface{}, err error) {
+	if !nc.cache(key, err) {
+		return
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	now := nc.clock.Now()
+	if _, ok := nc.entries[key]; !ok && len(nc.entries) >= nc.size {
+		for k, e := range nc.entries {
+			if !now.Before(e.expiration) {
+				delete(nc.entries, k)
+			}
+		}
+		// still full, drop any entry
+		for k := range nc.entries {
+			if len(nc.entries) < nc.size {
+				break
+			}
+			delete(nc.entries, k)
+		}
+	}
+	nc.entries[key] = negativeEntry{err: err, expiration: now.Add(nc.ttl)}
+}
+
+// cachedError returns the cached loader error of key, if any.
+func (c *baseCache) cachedError(key interface{}) (error, bool) {
+	if c.negatives == nil {
+		return nil, false
+	}
+	return c.negatives.get(key)
+}
+
+// cacheError caches a loader error of key, if NegativeCacheTTL is set and the error qualifies.
+func (c *baseCache) cacheError(key interface{}, err error) {
+	if c.negatives != nil {
+		c.negatives.set(key, err)
+	}
+}
+
+// forgetError drops the cached loader error of key.
+func (c *baseCache) forgetError(key interface{}) {
+	if nc := c.negatives; nc != nil {
+		nc.mu.Lock()
+		delete(nc.entries, key)
+		nc.mu.Unlock()
+	}
+}
+
+// forgetErrors drops every cached loader error.
+func (c *baseCache) forgetErrors() {
+	if nc := c.negatives; nc != nil {
+		nc.mu.Lock()
+		nc.entries = make(map[interface{}]negativeEntry)
+		nc.mu.Unlock()
+	}
+}
@@ -0,0 +1,163 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var errNoRow = errors.New("no row")
+
+func TestNegativeCacheTTL(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		loads := 0
+		clock := NewFakeClock()
+		gc := New(10).
+			EvictType(tp).
+			Clock(clock).
+			NegativeCacheTTL(time.Minute).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				loads++
+				return nil, errNoRow
+			}).
+			Build()
+
+		for i := 0; i < 3; i++ {
+			if _, err := gc.Get("a"); err != errNoRow {
+				t.Fatalf("%s: expected errNoRow, got %v", tp, err)
+			}
+		}
+		if _, err := gc.GetIFPresent("a"); err != KeyNotFoundError {
+			t.Errorf("%s: expected KeyNotFoundError from GetIFPresent, got %v", tp, err)
+		}
+		if loads != 1 {
+			t.Errorf("%s: expected 1 load, got %d", tp, loads)
+		}
+		if st := gc.Stats(); st.NegativeHitCount != 3 || st.MissCount != 4 {
+			t.Errorf("%s: expected 3 negative hits out of 4 misses, got %d and %d", tp, st.NegativeHitCount, st.MissCount)
+		}
+
+		clock.Advance(2 * time.Minute)
+		gc.Get("a")
+		if loads != 2 {
+			t.Errorf("%s: expected a load once the TTL passed, got %d loads", tp, loads)
+		}
+	}
+}
+
+func TestNegativeCacheSkipsTimeouts(t *testing.T) {
+	loads := 0
+	gc := New(10).
+		LRU().
+		NegativeCacheTTL(time.Minute).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			loads++
+			return nil, fmt.Errorf("query: %w", context.DeadlineExceeded)
+		}).
+		Build()
+	gc.Get("a")
+	gc.Get("a")
+	if loads != 2 {
+		t.Errorf("expected timeouts not to be cached, got %d loads", loads)
+	}
+}
+
+func TestNegativeCacheFunc(t *testing.T) {
+	loads := 0
+	gc := New(10).
+		LRU().
+		NegativeCacheTTL(time.Minute).
+		NegativeCacheFunc(func(key interface{}, err error) bool {
+			return errors.Is(err, errNoRow)
+		}).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			loads++
+			if key == "a" {
+				return nil, errNoRow
+			}
+			return nil, errors.New("connection refused")
+		}).
+		Build()
+	gc.Get("a")
+	gc.Get("a")
+	gc.Get("b")
+	gc.Get("b")
+	if loads != 3 {
+		t.Errorf("expected only errNoRow to be cached, got %d loads", loads)
+	}
+}
+
+func TestNegativeCacheForgetsErrors(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		loads := 0
+		gc := New(10).
+			EvictType(tp).
+			NegativeCacheTTL(time.Minute).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				loads++
+				return nil, errNoRow
+			}).
+			Build()
+
+		gc.Get("a")
+		gc.Set("a", 1)
+		gc.Remove("a")
+		gc.Get("a")
+		if loads != 2 {
+			t.Errorf("%s: expected Set to drop the cached error, got %d loads", tp, loads)
+		}
+		gc.Remove("a")
+		gc.Get("a")
+		if loads != 3 {
+			t.Errorf("%s: expected Remove to drop the cached error, got %d loads", tp, loads)
+		}
+		gc.Purge()
+		gc.Get("a")
+		if loads != 4 {
+			t.Errorf("%s: expected Purge to drop the cached errors, got %d loads", tp, loads)
+		}
+	}
+}
+
+func TestNegativeCacheIsBounded(t *testing.T) {
+	gc := New(10).
+		LRU().
+		NegativeCacheTTL(time.Minute).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			return nil, errNoRow
+		}).
+		Build()
+	for i := 0; i < 100; i++ {
+		gc.Get(i)
+	}
+	if n := len(gc.(*LRUCache).negatives.entries); n != 10 {
+		t.Errorf("expected 10 cached errors, got %d", n)
+	}
+}
+
+func TestTypedNegativeCache(t *testing.T) {
+	loads := 0
+	tc := NewTyped[int, string](10).
+		LRU().
+		NegativeCacheTTL(time.Minute).
+		NegativeCacheFunc(func(key int, err error) bool {
+			return key > 0
+		}).
+		LoaderFunc(func(key int) (string, error) {
+			loads++
+			return "", errNoRow
+		}).
+		Build()
+	tc.Get(1)
+	tc.Get(1)
+	tc.Get(0)
+	tc.Get(0)
+	if loads != 3 {
+		t.Errorf("expected 3 loads, got %d", loads)
+	}
+	if n := tc.Stats().NegativeHitCount; n != 1 {
+		t.Errorf("expected 1 negative hit, got %d", n)
+	}
+}
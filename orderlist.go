@@ -0,0 +1,78 @@
+package gcache
+
+import "container/list"
+
+// listIndex keeps the keys of an unsorted ordered cache in a doubly linked list,
+// the map finds the element of a key, so every operation takes O(1).
+type listIndex struct {
+	list     *list.List
+	elements map[interface{}]*list.Element
+}
+
+func newListIndex() *listIndex {
+	l := &listIndex{}
+	l.reset()
+	return l
+}
+
+func (l *listIndex) push(key, value interface{}) {
+	if e, ok := l.elements[key]; ok {
+		l.list.MoveToBack(e)
+		return
+	}
+	l.elements[key] = l.list.PushBack(key)
+}
+
+func (l *listIndex) pushFront(key, value interface{}) {
+	if e, ok := l.elements[key]; ok {
+		l.list.MoveToFront(e)
+		return
+	}
+	l.elements[key] = l.list.PushFront(key)
+}
+
+// update keeps the position of key, the queue does not depend on the values.
+func (l *listIndex) update(key, value interface{}) {}
+
+func (l *listIndex) remove(key interface{}) bool {
+	e, ok := l.elements[key]
+	if !ok {
+		return false
+	}
+	delete(l.elements, key)
+	l.list.Remove(e)
+	return true
+}
+
+// moveFront moves key to the front of the queue, it reports whether key is queued.
+func (l *listIndex) moveFront(key interface{}) bool {
+	e, ok := l.elements[key]
+	if ok {
+		l.list.MoveToFront(e)
+	}
+	return ok
+}
+
+func (l *listIndex) front() (interface{}, bool) {
+	if e := l.list.Front(); e != nil {
+		return e.Value, true
+	}
+	return nil, false
+}
+
+func (l *listIndex) ascend(fn func(key interface{}) bool) {
+	for e := l.list.Front(); e != nil; e = e.Next() {
+		if !fn(e.Value) {
+			return
+		}
+	}
+}
+
+func (l *listIndex) len() int {
+	return l.list.Len()
+}
+
+func (l *listIndex) reset() {
+	l.list = list.New()
+	l.elements = make(map[interface{}]*list.Element)
+}
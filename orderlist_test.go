@@ -0,0 +1,104 @@
+package gcache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestListIndex(t *testing.T) {
+	l := newListIndex()
+	l.push("a", 1)
+	l.push("b", 2)
+	l.pushFront("c", 3)
+	l.push("a", 4)
+	if keys := indexKeys(l); !equalKeys(keys, "c", "b", "a") {
+		t.Errorf("unexpected order %v", keys)
+	}
+	if !l.moveFront("a") || l.moveFront("missing") {
+		t.Error("unexpected result of moveFront")
+	}
+	if !l.remove("b") || l.remove("b") {
+		t.Error("unexpected result of remove")
+	}
+	if keys := indexKeys(l); !equalKeys(keys, "a", "c") || l.len() != 2 {
+		t.Errorf("unexpected order %v", keys)
+	}
+	if k, ok := l.front(); k != "a" || !ok {
+		t.Errorf("expected a in front, got %v", k)
+	}
+	l.reset()
+	if _, ok := l.front(); ok || l.len() != 0 {
+		t.Error("expected an empty list")
+	}
+}
+
+func TestOrderedCacheQueue(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	for i := 0; i < 6; i++ {
+		c.EnQueue(i, i*10)
+	}
+	c.Prepend(6, 60)
+	c.PrependBatch([]interface{}{7, 8}, []interface{}{70, 80})
+	c.Remove(2)
+	if err := c.MoveFront(4); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveFront(2); err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError, got %v", err)
+	}
+	if keys := c.OrderedKeys(); !equalKeys(keys, 4, 7, 8, 6, 0, 1, 3, 5) {
+		t.Errorf("unexpected order %v", keys)
+	}
+	// moving a key does not leave its old position behind
+	if c.Len() != 8 || c.QueueLen() != 8 {
+		t.Errorf("expected 8 keys, got %d and %d", c.Len(), c.QueueLen())
+	}
+
+	if k, v, err := c.DeQueue(); k != 4 || v != 40 || err != nil {
+		t.Errorf("expected 4:40, got %v:%v, %v", k, v, err)
+	}
+	keys, values, err := c.DeQueueBatch(3)
+	if err != nil || !equalKeys(keys, 7, 8, 6) || !equalKeys(values, 70, 80, 60) {
+		t.Errorf("unexpected batch %v %v, %v", keys, values, err)
+	}
+	if k, v, err := c.GetTop(); k != 0 || v != 0 || err != nil {
+		t.Errorf("expected 0:0 at the top, got %v:%v, %v", k, v, err)
+	}
+}
+
+func TestOrderedCacheQueueSnapshot(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	for i := 0; i < 5; i++ {
+		c.EnQueue(i, i)
+	}
+	c.MoveFront(3)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newtestSimploOrderCache(nil)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.OrderedKeys(); !equalKeys(keys, 3, 0, 1, 2, 4) {
+		t.Errorf("unexpected order %v", keys)
+	}
+}
+
+func TestOrderedCacheSort(t *testing.T) {
+	c := New(10).SortKeysFunc(func(keys []interface{}, values []interface{}, getItem func(interface{}) (interface{}, bool)) ([]interface{}, bool) {
+		// reverse the queue and leave the first key out
+		var sorted []interface{}
+		for i := len(keys) - 1; i > 0; i-- {
+			sorted = append(sorted, keys[i])
+		}
+		return sorted, true
+	}).BuildOrderedCache()
+	for i := 0; i < 4; i++ {
+		c.EnQueue(i, i)
+	}
+	c.Sort()
+	if keys := c.OrderedKeys(); !equalKeys(keys, 3, 2, 1, 0) {
+		t.Errorf("unexpected order %v", keys)
+	}
+}
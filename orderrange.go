@@ -0,0 +1,112 @@
+package gcache
+
+// sortedIndex returns the tree of a cache sorted by the SearchCompareFunction.
+// The index is set when the cache is built, so it is read without the lock.
+func (c *SimpleOrderedCache) sortedIndex() *treeIndex {
+	t, ok := c.index.(*treeIndex)
+	if !ok {
+		panic("call only when items are sorted")
+	}
+	return t
+}
+
+// entry returns the key and the deserialized value of n, ok is false if its item expired.
+func (c *SimpleOrderedCache) entry(n *treeNode) (key, value interface{}, ok bool) {
+	item := c.items[n.key]
+	if item.IsExpired(nil) {
+		return nil, nil, false
+	}
+	value = item.value
+	if c.deserializeFunc != nil {
+		value, _ = c.deserializeFunc(n.key, value)
+	}
+	return n.key, value, true
+}
+
+// ceilingPos returns the position of the first key with a value greater than or equal to value,
+// only greater if above is set.
+func (c *SimpleOrderedCache) ceilingPos(t *treeIndex, value interface{}, above bool) int {
+	return Search(t.len(), func(i int) bool {
+		r := c.searchCmpFunc(t.at(i).value, value)
+		return r > 0 || r == 0 && !above
+	})
+}
+
+// Range calls fn in order for the keys with values between from and to, both included, until fn returns false.
+// Expired items are skipped but not removed. fn is called with the cache locked, it must not use the cache.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) Range(from, to interface{}, fn func(k, v interface{}) bool) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t.ascendFrom(c.ceilingPos(t, from, false), func(n *treeNode) bool {
+		if c.searchCmpFunc(n.value, to) > 0 {
+			return false
+		}
+		k, v, ok := c.entry(n)
+		return !ok || fn(k, v)
+	})
+}
+
+// Floor returns the last key with a value less than or equal to value, KeyNotFoundError if there is none.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) Floor(value interface{}) (interface{}, interface{}, error) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	var k, v interface{}
+	found := false
+	for i := c.ceilingPos(t, value, true) - 1; i >= 0 && !found; i-- {
+		k, v, found = c.entry(t.at(i))
+	}
+	c.mu.RUnlock()
+	return c.found(k, v, found)
+}
+
+// Ceiling returns the first key with a value greater than or equal to value, KeyNotFoundError if there is none.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) Ceiling(value interface{}) (interface{}, interface{}, error) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	var k, v interface{}
+	found := false
+	t.ascendFrom(c.ceilingPos(t, value, false), func(n *treeNode) bool {
+		k, v, found = c.entry(n)
+		return !found
+	})
+	c.mu.RUnlock()
+	return c.found(k, v, found)
+}
+
+// Rank returns the position of key in the sort order, or -1 if it is not stored.
+// Expired items keep their position until they are removed.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) Rank(key interface{}) int {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return t.rank(key)
+}
+
+// At returns the key at position i of the sort order, KeyNotFoundError if i is out of range or its item expired.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) At(i int) (interface{}, interface{}, error) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	var k, v interface{}
+	found := false
+	if n := t.at(i); i >= 0 && n != nil {
+		k, v, found = c.entry(n)
+	}
+	c.mu.RUnlock()
+	return c.found(k, v, found)
+}
+
+// found counts the result of a lookup by value or position.
+func (c *SimpleOrderedCache) found(key, value interface{}, ok bool) (interface{}, interface{}, error) {
+	if !ok {
+		c.stats.IncrMissCount()
+		return nil, nil, KeyNotFoundError
+	}
+	c.stats.IncrHitCount()
+	return key, value, nil
+}
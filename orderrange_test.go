@@ -0,0 +1,137 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func newRangeTestCache() *SimpleOrderedCache {
+	c := newtestSimploOrderCache(cmpInt)
+	// values 0, 10, 10, 20, ... 80
+	for i := 0; i < 9; i++ {
+		c.EnQueue(i, i*10)
+	}
+	c.EnQueue("dup", 10)
+	return c
+}
+
+func TestOrderedCacheRange(t *testing.T) {
+	c := newRangeTestCache()
+	var keys []interface{}
+	c.Range(10, 35, func(k, v interface{}) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if !equalKeys(keys, 1, "dup", 2, 3) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	keys = nil
+	c.Range(25, 100, func(k, v interface{}) bool {
+		keys = append(keys, k)
+		return len(keys) < 2
+	})
+	if !equalKeys(keys, 3, 4) {
+		t.Errorf("expected Range to stop after two keys, got %v", keys)
+	}
+
+	c.Range(81, 100, func(k, v interface{}) bool {
+		t.Errorf("unexpected key %v", k)
+		return true
+	})
+}
+
+func TestOrderedCacheFloorCeiling(t *testing.T) {
+	c := newRangeTestCache()
+	if k, v, err := c.Floor(15); k != "dup" || v != 10 || err != nil {
+		t.Errorf("expected dup:10, got %v:%v, %v", k, v, err)
+	}
+	if k, _, _ := c.Floor(20); k != 2 {
+		t.Errorf("expected 2, got %v", k)
+	}
+	if k, v, err := c.Ceiling(15); k != 2 || v != 20 || err != nil {
+		t.Errorf("expected 2:20, got %v:%v, %v", k, v, err)
+	}
+	if k, _, _ := c.Ceiling(10); k != 1 {
+		t.Errorf("expected 1, got %v", k)
+	}
+	if _, _, err := c.Floor(-1); err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError, got %v", err)
+	}
+	if _, _, err := c.Ceiling(81); err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError, got %v", err)
+	}
+}
+
+func TestOrderedCacheRankAt(t *testing.T) {
+	c := newRangeTestCache()
+	if r := c.Rank("dup"); r != 2 {
+		t.Errorf("expected rank 2, got %d", r)
+	}
+	if r := c.Rank("missing"); r != -1 {
+		t.Errorf("expected -1, got %d", r)
+	}
+	if k, v, err := c.At(3); k != 2 || v != 20 || err != nil {
+		t.Errorf("expected 2:20, got %v:%v, %v", k, v, err)
+	}
+	for _, i := range []int{-1, 10} {
+		if _, _, err := c.At(i); err != KeyNotFoundError {
+			t.Errorf("%d: expected KeyNotFoundError, got %v", i, err)
+		}
+	}
+}
+
+func TestOrderedCacheRangeSkipsExpired(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(10).Clock(clock).Expiration(time.Second).SearchCompareFunction(cmpInt).BuildOrderedCache()
+	c.EnQueue(1, 10)
+	clock.Advance(2 * time.Second)
+	c.EnQueue(2, 20)
+	if k, _, _ := c.Ceiling(0); k != 2 {
+		t.Errorf("expected the expired key to be skipped, got %v", k)
+	}
+	if _, _, err := c.Floor(15); err != KeyNotFoundError {
+		t.Errorf("expected KeyNotFoundError, got %v", err)
+	}
+	n := 0
+	c.Range(0, 100, func(k, v interface{}) bool {
+		n++
+		return true
+	})
+	// the expired item is skipped, but not removed
+	if n != 1 || c.QueueLen() != 2 {
+		t.Errorf("expected 1 key in range and 2 queued, got %d and %d", n, c.QueueLen())
+	}
+}
+
+func TestOrderedCacheRangeNeedsSort(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	c.Floor(1)
+}
+
+func TestTypedOrderedCacheRange(t *testing.T) {
+	c := NewTyped[string, int](10).
+		SearchCompareFunction(func(a, b int) int {
+			return a - b
+		}).
+		BuildOrderedCache()
+	c.EnQueue("a", 3)
+	c.EnQueue("b", 1)
+	c.EnQueue("c", 2)
+	var keys []interface{}
+	c.Range(2, 3, func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if !equalKeys(keys, "c", "a") {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if k, v, err := c.At(0); k != "b" || v != 1 || err != nil {
+		t.Errorf("expected b:1, got %v:%v, %v", k, v, err)
+	}
+}
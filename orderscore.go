@@ -0,0 +1,99 @@
+package gcache
+
+// UpdateScore sets the value of key and moves it to its new position, a missing key is added.
+// Unlike EnQueue it replaces the value of a stored key in a sorted cache.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) UpdateScore(key, value interface{}) error {
+	c.sortedIndex() // panics if the cache is not sorted
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forgetError(key)
+	_, err := c.push(key, value, true)
+	c.wakeWaiters()
+	return err
+}
+
+// TopN returns up to n keys from the front of the sort order, the end DeQueue takes from.
+// Sort by a descending SearchCompareFunction to get the highest scores first.
+// Expired items are skipped but not removed.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) TopN(n int) (keys []interface{}, values []interface{}) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	t.ascendFrom(0, func(node *treeNode) bool {
+		if len(keys) >= n {
+			return false
+		}
+		if k, v, ok := c.entry(node); ok {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		return true
+	})
+	return keys, values
+}
+
+// BottomN returns up to n keys from the back of the sort order, the last key first.
+// Expired items are skipped but not removed.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) BottomN(n int) (keys []interface{}, values []interface{}) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := t.len() - 1; i >= 0 && len(keys) < n; i-- {
+		if k, v, ok := c.entry(t.at(i)); ok {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+	}
+	return keys, values
+}
+
+// RankOf returns the position of key in the sort order like Rank, but KeyNotFoundError if key is missing or expired.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) RankOf(key interface{}) (int, error) {
+	t := c.sortedIndex()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	item, ok := c.items[key]
+	if !ok || item.IsExpired(nil) {
+		return -1, KeyNotFoundError
+	}
+	return t.rank(key), nil
+}
+
+// RemoveRangeByRank removes the keys from position start to stop, both included, and returns how many it removed.
+// Negative positions count from the back, -1 is the last key, like ZREMRANGEBYRANK of Redis.
+// It panics unless the cache is sorted by the SearchCompareFunction.
+func (c *SimpleOrderedCache) RemoveRangeByRank(start, stop int) int {
+	t := c.sortedIndex()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := t.len()
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return 0
+	}
+	keys := make([]interface{}, 0, stop-start+1)
+	t.ascendFrom(start, func(node *treeNode) bool {
+		keys = append(keys, node.key)
+		return len(keys) <= stop-start
+	})
+	for _, key := range keys {
+		c.forgetError(key)
+		c.delete(key, causeRemoved)
+	}
+	return len(keys)
+}
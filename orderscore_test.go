@@ -0,0 +1,108 @@
+package gcache
+
+import (
+	"testing"
+	"time"
+)
+
+func newLeaderboard() *SimpleOrderedCache {
+	// highest score first
+	c := New(100).SearchCompareFunction(func(a, b interface{}) int {
+		return cmpInt(b, a)
+	}).BuildOrderedCache().(*SimpleOrderedCache)
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		c.EnQueue(name, i*10)
+	}
+	return c
+}
+
+func TestOrderedCacheUpdateScore(t *testing.T) {
+	c := newLeaderboard()
+	if err := c.UpdateScore("a", 25); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateScore("f", 5); err != nil {
+		t.Fatal(err)
+	}
+	if keys := c.OrderedKeys(); !equalKeys(keys, "e", "d", "a", "c", "b", "f") {
+		t.Errorf("unexpected order %v", keys)
+	}
+	if v, _ := c.Get("a"); v != 25 {
+		t.Errorf("expected 25, got %v", v)
+	}
+	// EnQueue still refuses to replace a score
+	if err := c.EnQueue("a", 50); err == nil {
+		t.Error("expected an error for a duplicated key")
+	}
+}
+
+func TestOrderedCacheTopBottomN(t *testing.T) {
+	c := newLeaderboard()
+	keys, values := c.TopN(2)
+	if !equalKeys(keys, "e", "d") || !equalKeys(values, 40, 30) {
+		t.Errorf("unexpected top %v %v", keys, values)
+	}
+	keys, values = c.BottomN(2)
+	if !equalKeys(keys, "a", "b") || !equalKeys(values, 0, 10) {
+		t.Errorf("unexpected bottom %v %v", keys, values)
+	}
+	if keys, _ := c.TopN(10); len(keys) != 5 {
+		t.Errorf("expected all 5 keys, got %v", keys)
+	}
+}
+
+func TestOrderedCacheRankOf(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(10).Clock(clock).Expiration(time.Second).SearchCompareFunction(cmpInt).BuildOrderedCache()
+	c.EnQueue("old", 1)
+	clock.Advance(2 * time.Second)
+	c.EnQueue("new", 2)
+	if r, err := c.RankOf("new"); r != 1 || err != nil {
+		t.Errorf("expected rank 1, got %d, %v", r, err)
+	}
+	for _, key := range []string{"old", "missing"} {
+		if _, err := c.RankOf(key); err != KeyNotFoundError {
+			t.Errorf("%s: expected KeyNotFoundError, got %v", key, err)
+		}
+	}
+}
+
+func TestOrderedCacheRemoveRangeByRank(t *testing.T) {
+	c := newLeaderboard()
+	if n := c.RemoveRangeByRank(1, 2); n != 2 {
+		t.Errorf("expected 2 removed, got %d", n)
+	}
+	if keys := c.OrderedKeys(); !equalKeys(keys, "e", "b", "a") {
+		t.Errorf("unexpected order %v", keys)
+	}
+	// keep the top entry only
+	if n := c.RemoveRangeByRank(1, -1); n != 2 {
+		t.Errorf("expected 2 removed, got %d", n)
+	}
+	if n := c.RemoveRangeByRank(5, 10); n != 0 {
+		t.Errorf("expected nothing removed, got %d", n)
+	}
+	if keys := c.OrderedKeys(); !equalKeys(keys, "e") || c.Len() != 1 {
+		t.Errorf("unexpected order %v", keys)
+	}
+	if st := c.Stats(); st.RemovalCount != 4 {
+		t.Errorf("expected 4 removals, got %d", st.RemovalCount)
+	}
+}
+
+func TestTypedOrderedCacheScores(t *testing.T) {
+	c := NewTyped[string, int](10).
+		SearchCompareFunction(func(a, b int) int {
+			return b - a
+		}).
+		BuildOrderedCache()
+	c.EnQueue("a", 1)
+	c.EnQueue("b", 2)
+	c.UpdateScore("a", 3)
+	if keys, _ := c.TopN(1); len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("expected a on top, got %v", keys)
+	}
+	if r, err := c.RankOf("b"); r != 1 || err != nil {
+		t.Errorf("expected rank 1, got %d, %v", r, err)
+	}
+}
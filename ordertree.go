@@ -0,0 +1,256 @@
+package gcache
+
+// treeNode is a node of a treap, size counts the nodes of its subtree for rank lookups.
+type treeNode struct {
+	key      interface{}
+	value    interface{}
+	seq      int64
+	priority uint64
+	size     int
+	left     *treeNode
+	right    *treeNode
+}
+
+func nodeSize(n *treeNode) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *treeNode) resize() {
+	n.size = 1 + nodeSize(n.left) + nodeSize(n.right)
+}
+
+// treeIndex keeps the keys of a sorted ordered cache in an order-statistic treap, so insert, remove,
+// rank and positional lookups take O(log n) without copying a slice.
+// Keys with equal values keep the order they were added in, like heapIndex.
+type treeIndex struct {
+	cmp      SearchCompareFunction
+	root     *treeNode
+	nodes    map[interface{}]*treeNode
+	backSeq  int64
+	frontSeq int64
+	// rnd is the state of the xorshift generator of the node priorities
+	rnd uint64
+}
+
+func newTreeIndex(cmp SearchCompareFunction) *treeIndex {
+	t := &treeIndex{cmp: cmp}
+	t.reset()
+	return t
+}
+
+func (t *treeIndex) less(a, b *treeNode) bool {
+	if c := t.cmp(a.value, b.value); c != 0 {
+		return c < 0
+	}
+	return a.seq < b.seq
+}
+
+func (t *treeIndex) nextPriority() uint64 {
+	t.rnd ^= t.rnd << 13
+	t.rnd ^= t.rnd >> 7
+	t.rnd ^= t.rnd << 17
+	return t.rnd
+}
+
+// split divides the subtree n into the nodes before pivot and the others.
+func (t *treeIndex) split(n, pivot *treeNode) (*treeNode, *treeNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if t.less(n, pivot) {
+		l, r := t.split(n.right, pivot)
+		n.right = l
+		n.resize()
+		return n, r
+	}
+	l, r := t.split(n.left, pivot)
+	n.left = r
+	n.resize()
+	return l, n
+}
+
+// merge joins two subtrees, every node of l comes before the nodes of r.
+func merge(l, r *treeNode) *treeNode {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.priority > r.priority {
+		l.right = merge(l.right, r)
+		l.resize()
+		return l
+	}
+	r.left = merge(l, r.left)
+	r.resize()
+	return r
+}
+
+func (t *treeIndex) insert(n, node *treeNode) *treeNode {
+	if n == nil {
+		return node
+	}
+	if node.priority > n.priority {
+		node.left, node.right = t.split(n, node)
+		node.resize()
+		return node
+	}
+	if t.less(node, n) {
+		n.left = t.insert(n.left, node)
+	} else {
+		n.right = t.insert(n.right, node)
+	}
+	n.size++
+	return n
+}
+
+func (t *treeIndex) delete(n, node *treeNode) *treeNode {
+	if n == node {
+		return merge(n.left, n.right)
+	}
+	if t.less(node, n) {
+		n.left = t.delete(n.left, node)
+	} else {
+		n.right = t.delete(n.right, node)
+	}
+	n.size--
+	return n
+}
+
+func (t *treeIndex) add(key, value interface{}, seq int64) {
+	if n, ok := t.nodes[key]; ok {
+		t.root = t.delete(t.root, n)
+		delete(t.nodes, key)
+	}
+	n := &treeNode{key: key, value: value, seq: seq, priority: t.nextPriority(), size: 1}
+	t.nodes[key] = n
+	t.root = t.insert(t.root, n)
+}
+
+func (t *treeIndex) push(key, value interface{}) {
+	t.backSeq++
+	t.add(key, value, t.backSeq)
+}
+
+func (t *treeIndex) pushFront(key, value interface{}) {
+	t.frontSeq--
+	t.add(key, value, t.frontSeq)
+}
+
+// update moves key to the position of value, among equal values it keeps its place.
+func (t *treeIndex) update(key, value interface{}) {
+	n, ok := t.nodes[key]
+	if !ok {
+		return
+	}
+	t.root = t.delete(t.root, n)
+	n.value = value
+	n.left, n.right, n.size = nil, nil, 1
+	t.root = t.insert(t.root, n)
+}
+
+func (t *treeIndex) remove(key interface{}) bool {
+	n, ok := t.nodes[key]
+	if !ok {
+		return false
+	}
+	delete(t.nodes, key)
+	t.root = t.delete(t.root, n)
+	return true
+}
+
+func (t *treeIndex) front() (interface{}, bool) {
+	n := t.root
+	if n == nil {
+		return nil, false
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n.key, true
+}
+
+func (t *treeIndex) ascend(fn func(key interface{}) bool) {
+	t.ascendFrom(0, func(n *treeNode) bool {
+		return fn(n.key)
+	})
+}
+
+// ascendFrom calls fn for the nodes from position i on until it returns false.
+func (t *treeIndex) ascendFrom(i int, fn func(n *treeNode) bool) {
+	var stack []*treeNode
+	for n := t.root; n != nil; {
+		l := nodeSize(n.left)
+		if i > l {
+			i -= l + 1
+			n = n.right
+			continue
+		}
+		stack = append(stack, n)
+		if i == l {
+			break
+		}
+		n = n.left
+	}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !fn(n) {
+			return
+		}
+		for m := n.right; m != nil; m = m.left {
+			stack = append(stack, m)
+		}
+	}
+}
+
+// rank returns the position of key in the queue, or -1.
+func (t *treeIndex) rank(key interface{}) int {
+	node, ok := t.nodes[key]
+	if !ok {
+		return -1
+	}
+	r := 0
+	for n := t.root; n != node; {
+		if t.less(node, n) {
+			n = n.left
+		} else {
+			r += nodeSize(n.left) + 1
+			n = n.right
+		}
+	}
+	return r + nodeSize(node.left)
+}
+
+// at returns the node at position i of the queue, or nil.
+func (t *treeIndex) at(i int) *treeNode {
+	n := t.root
+	for n != nil {
+		l := nodeSize(n.left)
+		switch {
+		case i < l:
+			n = n.left
+		case i == l:
+			return n
+		default:
+			i -= l + 1
+			n = n.right
+		}
+	}
+	return nil
+}
+
+func (t *treeIndex) len() int {
+	return nodeSize(t.root)
+}
+
+func (t *treeIndex) reset() {
+	t.root = nil
+	t.nodes = make(map[interface{}]*treeNode)
+	t.backSeq, t.frontSeq = 0, 0
+	t.rnd = 0x9e3779b97f4a7c15
+}
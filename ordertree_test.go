@@ -0,0 +1,116 @@
+package gcache
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkTree compares the tree with the expected keys and their positions.
+func checkTree(t *testing.T, tree *treeIndex, expected []interface{}) {
+	t.Helper()
+	if keys := indexKeys(tree); !equalKeys(keys, expected...) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+	if tree.len() != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), tree.len())
+	}
+	for i, key := range expected {
+		if r := tree.rank(key); r != i {
+			t.Fatalf("expected rank %d for %v, got %d", i, key, r)
+		}
+		if n := tree.at(i); n == nil || n.key != key {
+			t.Fatalf("expected %v at %d, got %v", key, i, n)
+		}
+	}
+	if tree.at(len(expected)) != nil || tree.rank("missing") != -1 {
+		t.Fatal("expected no node out of range")
+	}
+}
+
+func TestTreeIndex(t *testing.T) {
+	tree := newTreeIndex(cmpInt)
+	tree.push("a", 2)
+	tree.push("b", 1)
+	tree.push("c", 2)
+	tree.pushFront("d", 2)
+	tree.push("e", 3)
+	checkTree(t, tree, []interface{}{"b", "d", "a", "c", "e"})
+
+	// b keeps the place it was added in among the equal values
+	tree.update("b", 2)
+	checkTree(t, tree, []interface{}{"d", "a", "b", "c", "e"})
+	if !tree.remove("a") || tree.remove("a") {
+		t.Error("unexpected result of remove")
+	}
+	checkTree(t, tree, []interface{}{"d", "b", "c", "e"})
+	if k, ok := tree.front(); k != "d" || !ok {
+		t.Errorf("expected d in front, got %v", k)
+	}
+	tree.reset()
+	checkTree(t, tree, nil)
+}
+
+func TestTreeIndexRandom(t *testing.T) {
+	type entry struct {
+		key, value, seq int
+	}
+	tree := newTreeIndex(cmpInt)
+	r := rand.New(rand.NewSource(1))
+	entries := make(map[int]entry)
+	for i := 0; i < 5000; i++ {
+		k := r.Intn(300)
+		if r.Intn(3) == 0 {
+			tree.remove(k)
+			delete(entries, k)
+			continue
+		}
+		v := r.Intn(50)
+		tree.push(k, v)
+		entries[k] = entry{k, v, i}
+	}
+	sorted := make([]entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].value != sorted[j].value {
+			return sorted[i].value < sorted[j].value
+		}
+		return sorted[i].seq < sorted[j].seq
+	})
+	expected := make([]interface{}, len(sorted))
+	for i, e := range sorted {
+		expected[i] = e.key
+	}
+	checkTree(t, tree, expected)
+}
+
+func TestSortedOrderedCache(t *testing.T) {
+	c := newtestSimploOrderCache(cmpInt)
+	for i := 0; i < 10; i++ {
+		c.EnQueue(i, i%3)
+	}
+	if err := c.EnQueue(0, 5); err == nil {
+		t.Error("expected an error for a duplicated key")
+	}
+	c.Prepend(10, 1)
+	c.EnQueueBatch([]interface{}{11, 12}, []interface{}{2, 0})
+	expected := []interface{}{0, 3, 6, 9, 12, 10, 1, 4, 7, 2, 5, 8, 11}
+	if keys := c.OrderedKeys(); !equalKeys(keys, expected...) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	c.Remove(4)
+	c.Remove(0)
+	if k, v, _ := c.DeQueue(); k != 3 || v != 0 {
+		t.Errorf("expected 3:0, got %v:%v", k, v)
+	}
+	keys, _, _ := c.DeQueueBatch(4)
+	if !equalKeys(keys, 6, 9, 12, 10) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if keys := c.OrderedKeys(); c.Len() != 6 || !equalKeys(keys, 1, 7, 2, 5, 8, 11) {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
@@ -0,0 +1,110 @@
+package gcache
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// wait queues a waiter for new items, front puts it before the others, it is called with the lock held.
+func (c *SimpleOrderedCache) wait(front bool) *list.Element {
+	ch := make(chan struct{}, 1)
+	if front {
+		return c.waiters.PushFront(ch)
+	}
+	return c.waiters.PushBack(ch)
+}
+
+// wakeWaiters signals the oldest waiters, one for each live item, it is called with the lock held.
+// Expired items are dropped first so they do not wake waiters which would find nothing to dequeue.
+func (c *SimpleOrderedCache) wakeWaiters() {
+	if c.waiters.Len() == 0 {
+		return
+	}
+	c.dropExpired()
+	for n := c.index.len(); n > 0 && c.waiters.Len() > 0; n-- {
+		ch := c.waiters.Remove(c.waiters.Front()).(chan struct{})
+		ch <- struct{}{}
+	}
+}
+
+// cancelWait removes a waiter which stopped waiting, a signal it got already is passed on to the next one.
+func (c *SimpleOrderedCache) cancelWait(e *list.Element) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiters.Remove(e)
+	if len(e.Value.(chan struct{})) > 0 {
+		c.wakeWaiters()
+	}
+}
+
+// DeQueueWait works like DeQueue, but blocks until an item is queued or ctx is done.
+// Waiting goroutines are served in the order they started to wait.
+func (c *SimpleOrderedCache) DeQueueWait(ctx context.Context) (key interface{}, value interface{}, err error) {
+	front := false
+	for {
+		c.mu.Lock()
+		key, value, found := c.first(true)
+		if found {
+			c.mu.Unlock()
+			c.stats.IncrHitCount()
+			return key, value, nil
+		}
+		e := c.wait(front)
+		c.mu.Unlock()
+
+		select {
+		case <-e.Value.(chan struct{}):
+			// keep the place in line if another consumer took the item first
+			front = true
+		case <-ctx.Done():
+			c.cancelWait(e)
+			c.stats.IncrMissCount()
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// DeQueueBatchWait works like DeQueueBatch, but blocks until an item is queued or ctx is done.
+// Once it has taken an item it waits up to maxWait for more, it returns as soon as it has max items.
+// The items it has taken are returned without an error when ctx ends.
+func (c *SimpleOrderedCache) DeQueueBatchWait(ctx context.Context, max int, maxWait time.Duration) (keys []interface{}, values []interface{}, err error) {
+	if max <= 0 {
+		return c.deQueueBatch(max)
+	}
+	var timeout <-chan time.Time
+	stopTimer := func() {}
+	defer func() { stopTimer() }()
+	front := false
+	for {
+		c.mu.Lock()
+		k, v := c.take(max - len(keys))
+		keys = append(keys, k...)
+		values = append(values, v...)
+		if len(keys) >= max || len(keys) > 0 && maxWait <= 0 {
+			c.mu.Unlock()
+			break
+		}
+		if len(keys) > 0 && timeout == nil {
+			timeout, stopTimer = after(c.clock, maxWait)
+		}
+		e := c.wait(front)
+		c.mu.Unlock()
+
+		select {
+		case <-e.Value.(chan struct{}):
+			front = true
+			continue
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		c.cancelWait(e)
+		break
+	}
+	if len(keys) == 0 {
+		c.stats.IncrMissCount()
+		return nil, nil, ctx.Err()
+	}
+	c.stats.IncrHitCount()
+	return keys, values, nil
+}
@@ -0,0 +1,208 @@
+package gcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until n goroutines are blocked in c.
+func waitForWaiters(t *testing.T, c *SimpleOrderedCache, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		c.mu.Lock()
+		l := c.waiters.Len()
+		c.mu.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiters", n)
+}
+
+func TestDeQueueWait(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	c.EnQueue("ready", 1)
+	if k, _, err := c.DeQueueWait(context.Background()); k != "ready" || err != nil {
+		t.Errorf("expected ready, got %v, %v", k, err)
+	}
+
+	done := make(chan interface{})
+	go func() {
+		k, _, _ := c.DeQueueWait(context.Background())
+		done <- k
+	}()
+	waitForWaiters(t, c, 1)
+	c.Prepend("later", 2)
+	if k := <-done; k != "later" {
+		t.Errorf("expected later, got %v", k)
+	}
+}
+
+func TestDeQueueWaitContext(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := c.DeQueueWait(ctx)
+		done <- err
+	}()
+	waitForWaiters(t, c, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	waitForWaiters(t, c, 0)
+}
+
+func TestDeQueueWaitFair(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	const n = 5
+	results := make([]chan interface{}, n)
+	for i := range results {
+		results[i] = make(chan interface{}, 1)
+		go func(ch chan interface{}) {
+			k, _, _ := c.DeQueueWait(context.Background())
+			ch <- k
+		}(results[i])
+		// start waiting in order
+		waitForWaiters(t, c, i+1)
+	}
+	// every item wakes the oldest waiter
+	for i := 0; i < n; i++ {
+		c.EnQueue(i, i)
+		if k := <-results[i]; k != i {
+			t.Errorf("expected waiter %d to get %d, got %v", i, i, k)
+		}
+	}
+}
+
+func TestDeQueueWaitMany(t *testing.T) {
+	c := newtestSimploOrderCache(nil)
+	const consumers, items = 8, 1000
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[interface{}]bool)
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				k, _, err := c.DeQueueWait(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if k == "stop" {
+					return
+				}
+				mu.Lock()
+				seen[k] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < items; i++ {
+		if i%2 == 0 {
+			c.EnQueue(i, i)
+		} else {
+			c.EnQueueBatch([]interface{}{i}, []interface{}{i})
+		}
+	}
+	// stop the consumers once the items are taken
+	for c.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < consumers; i++ {
+		c.EnQueue("stop", nil)
+		for c.Len() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wg.Wait()
+	if len(seen) != items {
+		t.Errorf("expected %d items, got %d", items, len(seen))
+	}
+}
+
+func TestDeQueueBatchWait(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(100).Clock(clock).BuildOrderedCache().(*SimpleOrderedCache)
+	type result struct {
+		keys []interface{}
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		keys, _, err := c.DeQueueBatchWait(context.Background(), 3, time.Second)
+		done <- result{keys, err}
+	}()
+	waitForWaiters(t, c, 1)
+	c.EnQueue(1, 1)
+	// the first item starts the timer, the batch waits for more
+	waitForWaiters(t, c, 1)
+	c.EnQueueBatch([]interface{}{2, 3, 4}, []interface{}{2, 3, 4})
+	if r := <-done; !equalKeys(r.keys, 1, 2, 3) || r.err != nil {
+		t.Errorf("expected a full batch, got %v, %v", r.keys, r.err)
+	}
+	// the maxWait timer is stopped
+	fc := clock.(*fakeclock)
+	fc.mutex.RLock()
+	if n := len(fc.waiters); n != 0 {
+		t.Errorf("%v != %v", n, 0)
+	}
+	fc.mutex.RUnlock()
+
+	go func() {
+		keys, _, err := c.DeQueueBatchWait(context.Background(), 3, time.Second)
+		done <- result{keys, err}
+	}()
+	// 4 is taken at once, the batch is returned after maxWait
+	waitForWaiters(t, c, 1)
+	clock.Advance(2 * time.Second)
+	if r := <-done; !equalKeys(r.keys, 4) || r.err != nil {
+		t.Errorf("expected 4 after maxWait, got %v, %v", r.keys, r.err)
+	}
+	waitForWaiters(t, c, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if keys, _, err := c.DeQueueBatchWait(ctx, 3, time.Second); keys != nil || err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v, %v", keys, err)
+	}
+}
+
+func TestTypedDeQueueWait(t *testing.T) {
+	c := NewTyped[string, int](10).BuildOrderedCache()
+	go c.EnQueue("a", 1)
+	if k, v, err := c.DeQueueWait(context.Background()); k != "a" || v != 1 || err != nil {
+		t.Errorf("expected a:1, got %v:%v, %v", k, v, err)
+	}
+}
+
+func TestWakeWaitersSkipsExpired(t *testing.T) {
+	clock := NewFakeClock()
+	c := New(10).Clock(clock).Expiration(time.Minute).BuildOrderedCache().(*SimpleOrderedCache)
+	c.EnQueue(1, "a")
+	c.EnQueue(2, "b")
+	clock.Advance(2 * time.Minute)
+
+	c.mu.Lock()
+	var waiters []chan struct{}
+	for i := 0; i < 3; i++ {
+		waiters = append(waiters, c.wait(false).Value.(chan struct{}))
+	}
+	c.mu.Unlock()
+	if err := c.EnQueue(3, "c"); err != nil {
+		t.Fatal(err)
+	}
+	woken := 0
+	for _, ch := range waiters {
+		woken += len(ch)
+	}
+	if woken != 1 {
+		t.Errorf("%v waiters were woken for a single live item", woken)
+	}
+}
@@ -0,0 +1,694 @@
+package gcache
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EvictionPolicy decides which item leaves a full cache. The cache stores the items and
+// takes care of expiration, loaders, weights and callbacks, the policy only tracks keys.
+// Its methods are called with the cache lock held, so it needs no locking of its own,
+// but it must not call back into the cache.
+type EvictionPolicy interface {
+	// OnAdd is called after key was stored in the cache.
+	OnAdd(key interface{})
+	// OnAccess is called when a lookup finds key, and when the value of key is replaced.
+	OnAccess(key interface{})
+	// OnRemove is called after key left the cache, because it was evicted, expired or removed.
+	OnRemove(key interface{})
+	// Victim returns the key which should be evicted next, or false if the policy tracks no key.
+	Victim() (interface{}, bool)
+	// Reset forgets every key, it is called by Purge and Load.
+	Reset()
+}
+
+// passiveAccess is implemented by policies whose OnAccess only marks the key, with atomic
+// operations and without changing their lists. Lookups in their caches only take the read lock,
+// so OnAccess of a lookup runs concurrently with other lookups.
+type passiveAccess interface {
+	passiveAccess()
+}
+
+// lookupRecorder is implemented by policies which take the lookups in batches, hits and misses alike.
+// Lookups in their caches only take the read lock and buffer the key, OnAccess is not called for them.
+// The buffered keys are passed to onLookups with the cache lock held, before the cache changes
+// and whenever the buffer fills up.
+type lookupRecorder interface {
+	onLookups(keys []interface{})
+}
+
+const lookupBufferSize = 64
+
+// lookupBuffer collects the lookups for a lookupRecorder.
+type lookupBuffer struct {
+	mu   sync.Mutex
+	keys []interface{}
+}
+
+// add buffers key and reports whether it filled the buffer.
+func (b *lookupBuffer) add(key interface{}) bool {
+	b.mu.Lock()
+	b.keys = append(b.keys, key)
+	full := len(b.keys) == lookupBufferSize
+	b.mu.Unlock()
+	return full
+}
+
+// take empties the buffer and returns the keys it held.
+func (b *lookupBuffer) take() []interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.keys) == 0 {
+		return nil
+	}
+	keys := b.keys
+	b.keys = make([]interface{}, 0, lookupBufferSize)
+	return keys
+}
+
+// policySnapshot is implemented by the built-in policies, which keep their state in snapshots.
+// Other policies get the entries of the cache in no particular order from Load, through OnAdd.
+type policySnapshot interface {
+	// save collects the state of the policy, the returned function writes it once the cache lock is released.
+	save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error
+	// load reads the state written by save, the returned function restores it with the cache lock held.
+	// add stores an entry in the cache and reports whether it fit.
+	load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error)
+}
+
+// policyCache is the cache core shared by every EvictionPolicy.
+type policyCache struct {
+	baseCache
+	items  map[interface{}]*policyItem
+	policy EvictionPolicy
+	// lookups is set if the policy is a lookupRecorder
+	lookups *lookupBuffer
+	// shardCount counts the items of all the shards of a ShardedCache
+	shardCount *int64
+	// shardWeight is the weight of all the shards of a ShardedCache
+	shardWeight *int64
+	// kind identifies the policy in snapshots
+	kind    string
+	passive bool
+}
+
+func newPolicyCache(cb *CacheBuilder) *policyCache {
+	c := &policyCache{}
+	c.setup(cb, TYPE_POLICY, cb.policy)
+	return c
+}
+
+func (c *policyCache) setup(cb *CacheBuilder, kind string, p EvictionPolicy) {
+	buildCache(&c.baseCache, cb)
+	c.kind = kind
+	c.policy = p
+	c.shardCount = cb.shardCount
+	c.shardWeight = cb.shardWeight
+	_, c.passive = p.(passiveAccess)
+	if _, ok := p.(lookupRecorder); ok && !c.passive {
+		c.lookups = &lookupBuffer{}
+	}
+
+	c.init()
+	c.loadGroup.cache = c
+	c.startJanitor(cb.cleanupInterval, c.deleteExpired)
+}
+
+func (c *policyCache) init() {
+	c.count(-len(c.items))
+	if c.size <= 0 {
+		c.items = make(map[interface{}]*policyItem)
+	} else {
+		c.items = make(map[interface{}]*policyItem, c.size+1)
+	}
+	c.policy.Reset()
+	if c.lookups != nil {
+		c.lookups.take()
+	}
+	c.expiries.reset()
+	c.addWeight(-c.weight)
+	c.forgetErrors()
+}
+
+// Set a new key-value pair
+func (c *policyCache) Set(key, value interface{}) error {
+	c.trace(TraceSet, key, 0, value)
+	c.forgetError(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.set(key, value)
+	return err
+}
+
+// Set a new key-value pair with an expiration time
+func (c *policyCache) SetWithExpire(key, value interface{}, expiration time.Duration) error {
+	c.trace(TraceSet, key, 0, value)
+	c.forgetError(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, err := c.set(key, value)
+	if err != nil {
+		return err
+	}
+
+	t := c.clock.Now().Add(expiration)
+	item.expiration = &t
+	c.expiries.set(key, &t)
+	return nil
+}
+
+func (c *policyCache) set(key, value interface{}) (*policyItem, error) {
+	c.flushLookups()
+	var err error
+	if c.serializeFunc != nil {
+		value, err = c.serializeFunc(key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return nil, err
+	}
+	c.makeRoom(w, func() int64 {
+		if item, ok := c.items[key]; ok {
+			return item.weight
+		}
+		return 0
+	}, func() bool {
+		return c.evict(1) > 0
+	})
+
+	// Check for existing item
+	item, ok := c.items[key]
+	if ok {
+		item.value = value
+		c.policy.OnAccess(key)
+	} else {
+		// Verify size not exceeded
+		if c.size > 0 && len(c.items) >= c.size {
+			c.evict(1)
+			if len(c.items) >= c.size {
+				return nil, ReachedMaxSizeErr
+			}
+		}
+		item = &policyItem{
+			clock: c.clock,
+			key:   key,
+			value: value,
+		}
+		c.items[key] = item
+		c.count(1)
+		c.policy.OnAdd(key)
+	}
+	c.addWeight(w - item.weight)
+	item.weight = w
+
+	item.refreshAt = c.refreshTime()
+	if c.expiration != nil {
+		t := c.clock.Now().Add(*c.expiration)
+		item.expiration = &t
+		c.expiries.set(key, &t)
+	}
+
+	if c.addedFunc != nil {
+		c.addedFunc(key, value)
+	}
+
+	return item, nil
+}
+
+// Get a value from cache pool using key if it exists.
+// If it dose not exists key and has LoaderFunc,
+// generate a value using `LoaderFunc` method returns value.
+func (c *policyCache) Get(key interface{}) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx works like Get, but stops waiting for the loader once ctx is done.
+// The load itself keeps running for other callers of the same key.
+func (c *policyCache) GetCtx(ctx context.Context, key interface{}) (interface{}, error) {
+	v, err := c.get(key, false)
+	if err == KeyNotFoundError {
+		v, err = c.getWithLoader(ctx, key, true)
+		c.traceLookup(TraceGet, key, v, err, true)
+		return v, err
+	}
+	c.traceLookup(TraceGet, key, v, err, false)
+	return v, err
+}
+
+// Get a value from cache pool using key if it exists.
+// If it dose not exists key, returns KeyNotFoundError.
+// And send a request which refresh value for specified key if cache object has LoaderFunc.
+func (c *policyCache) GetIFPresent(key interface{}) (interface{}, error) {
+	v, loaded, err := c.getIFPresent(key)
+	c.traceLookup(TraceGetIFPresent, key, v, err, loaded)
+	return v, err
+}
+
+// getIFPresent works like GetIFPresent without recording the lookup in the trace,
+// loaded tells whether the loader was asked for the value.
+func (c *policyCache) getIFPresent(key interface{}) (v interface{}, loaded bool, err error) {
+	v, err = c.get(key, false)
+	if err == KeyNotFoundError {
+		v, err = c.getWithLoader(context.Background(), key, false)
+		return v, true, err
+	}
+	return v, false, err
+}
+
+func (c *policyCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	v, err := c.getValue(key, onLoad)
+	if err != nil {
+		return nil, err
+	}
+	if c.deserializeFunc != nil {
+		return c.deserializeFunc(key, v)
+	}
+	return v, nil
+}
+
+func (c *policyCache) getValue(key interface{}, onLoad bool) (interface{}, error) {
+	lock, unlock := c.mu.Lock, c.mu.Unlock
+	if c.passive || c.lookups != nil {
+		lock, unlock = c.mu.RLock, c.mu.RUnlock
+	}
+	lock()
+	item, ok := c.items[key]
+	if ok && !item.IsExpired(nil) {
+		refresh := c.refreshDue(item.refreshAt)
+		if refresh && onLoad {
+			// the loader group takes a stale item as missing, so it starts the refresh
+			unlock()
+			return nil, KeyNotFoundError
+		}
+		if c.lookups == nil {
+			c.policy.OnAccess(key)
+		}
+		v := item.value
+		unlock()
+		c.recordLookup(key)
+		if !onLoad {
+			c.stats.IncrHitCount()
+		}
+		if refresh {
+			c.getWithLoader(context.Background(), key, false)
+		}
+		return v, nil
+	}
+	unlock()
+	if !onLoad {
+		c.recordLookup(key)
+	}
+	if ok {
+		// the item may have been replaced since the lock was released
+		c.mu.Lock()
+		if cur, found := c.items[key]; found && cur == item && item.IsExpired(nil) {
+			c.remove(key, causeExpired)
+		}
+		c.mu.Unlock()
+	}
+	if !onLoad {
+		c.stats.IncrMissCount()
+	}
+	return nil, KeyNotFoundError
+}
+
+// recordLookup buffers a lookup for a lookupRecorder, the lookup which fills the buffer passes it on.
+func (c *policyCache) recordLookup(key interface{}) {
+	if c.lookups == nil || !c.lookups.add(key) {
+		return
+	}
+	c.mu.Lock()
+	c.flushLookups()
+	c.mu.Unlock()
+}
+
+// flushLookups passes the buffered lookups to the policy, it is called with the lock held.
+func (c *policyCache) flushLookups() {
+	if c.lookups == nil {
+		return
+	}
+	if keys := c.lookups.take(); keys != nil {
+		c.policy.(lookupRecorder).onLookups(keys)
+	}
+}
+
+func (c *policyCache) getWithLoader(ctx context.Context, key interface{}, isWait bool) (interface{}, error) {
+	if c.loaderFunc == nil {
+		return nil, KeyNotFoundError
+	}
+	value, _, err := c.load(ctx, key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
+		if e != nil {
+			c.postponeRefresh(key)
+			return nil, e
+		}
+		if err := c.store(key, v, expiration); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}, isWait)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// store sets a loaded value, a non nil expiration replaces the default one.
+func (c *policyCache) store(key, value interface{}, expiration *time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, err := c.set(key, value)
+	if err != nil {
+		return err
+	}
+	if expiration != nil {
+		t := c.clock.Now().Add(*expiration)
+		item.expiration = &t
+		c.expiries.set(key, &t)
+	}
+	return nil
+}
+
+// GetMany returns the values of keys which are stored or can be loaded.
+// With a BulkLoaderFunc all the misses are loaded by a single call.
+func (c *policyCache) GetMany(keys []interface{}) (map[interface{}]interface{}, error) {
+	return c.getMany(c.beginMany(keys))
+}
+
+func (c *policyCache) beginMany(keys []interface{}) *bulkBatch {
+	return c.newBatch(keys, c.get, c.store, c.getWithLoader)
+}
+
+// GetOrSet returns the value of key if it is stored, otherwise it sets value and returns it.
+// loaded tells whether the value was stored before. The loader is not called.
+func (c *policyCache) GetOrSet(key, value interface{}) (actual interface{}, loaded bool, err error) {
+	return c.getOrSet(c, key, value)
+}
+
+// SetIfAbsent sets value if key is not stored, and reports whether it did.
+func (c *policyCache) SetIfAbsent(key, value interface{}) (bool, error) {
+	return c.setIfAbsent(c, key, value)
+}
+
+// Compute atomically replaces the value of key with the result of f, or removes key if f says so.
+// It returns the new value, nil after a removal. f runs with the cache lock held, so it must not use the cache.
+func (c *policyCache) Compute(key interface{}, f ComputeFunc) (interface{}, error) {
+	return c.compute(c, key, f)
+}
+
+// CompareAndSwap sets new if the value of key equals old, and reports whether it did.
+// The values are compared with ==, if old is not comparable it returns NotComparableErr.
+func (c *policyCache) CompareAndSwap(key, old, new interface{}) (bool, error) {
+	return c.compareAndSwap(c, key, old, new)
+}
+
+func (c *policyCache) peek(key interface{}) (interface{}, bool) {
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.IsExpired(nil) {
+		c.remove(key, causeExpired)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (c *policyCache) put(key, value interface{}) error {
+	_, err := c.set(key, value)
+	return err
+}
+
+// evict removes count items and returns how many it removed.
+// Expired items go first, then the victims chosen by the policy.
+func (c *policyCache) evict(count int) int {
+	c.flushLookups()
+	now := c.clock.Now()
+	removed := 0
+	for ; removed < count; removed++ {
+		if key, ok := c.expiries.popExpired(now); ok {
+			c.remove(key, causeExpired)
+			continue
+		}
+		key, ok := c.policy.Victim()
+		if !ok || !c.remove(key, causeEvicted) {
+			break
+		}
+	}
+	return removed
+}
+
+// deleteExpired removes every expired item, it is run by the janitor.
+func (c *policyCache) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	for key, ok := c.expiries.popExpired(now); ok; key, ok = c.expiries.popExpired(now) {
+		c.remove(key, causeExpired)
+	}
+}
+
+func (c *policyCache) itemCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// count adds n to the item count of the ShardedCache the cache belongs to.
+func (c *policyCache) count(n int) {
+	if c.shardCount != nil && n != 0 {
+		atomic.AddInt64(c.shardCount, int64(n))
+	}
+}
+
+// addWeight adds w to the weight of the cache and of the ShardedCache it belongs to.
+func (c *policyCache) addWeight(w int64) {
+	c.weight += w
+	if c.shardWeight != nil && w != 0 {
+		atomic.AddInt64(c.shardWeight, w)
+	}
+}
+
+func (c *policyCache) evictOne() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evict(1) > 0
+}
+
+// Removes the provided key from the cache.
+func (c *policyCache) Remove(key interface{}) bool {
+	c.mu.Lock()
+	ok := c.remove(key, causeRemoved)
+	c.mu.Unlock()
+	c.forgetError(key)
+	c.traceRemove(key, ok)
+	return ok
+}
+
+func (c *policyCache) remove(key interface{}, cause removalCause) bool {
+	item, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	delete(c.items, key)
+	c.count(-1)
+	c.expiries.remove(key)
+	c.addWeight(-item.weight)
+	c.stats.incrRemoval(cause)
+	c.policy.OnRemove(key)
+	if c.evictedFunc != nil {
+		c.evictedFunc(key, item.value)
+	}
+	return true
+}
+
+// Returns a slice of the keys in the cache.
+// Like GetALL and Len it does not count as a lookup, neither in the stats nor for the policy.
+func (c *policyCache) Keys() []interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	keys := make([]interface{}, 0, len(c.items))
+	for k, item := range c.items {
+		if !item.IsExpired(&now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Returns all key-value pairs in the cache.
+func (c *policyCache) GetALL() map[interface{}]interface{} {
+	c.mu.RLock()
+	now := c.clock.Now()
+	m := make(map[interface{}]interface{}, len(c.items))
+	for k, item := range c.items {
+		if !item.IsExpired(&now) {
+			m[k] = item.value
+		}
+	}
+	c.mu.RUnlock()
+	if c.deserializeFunc != nil {
+		for k, v := range m {
+			if v, err := c.deserializeFunc(k, v); err == nil {
+				m[k] = v
+			} else {
+				delete(m, k)
+			}
+		}
+	}
+	return m
+}
+
+// Returns the number of items in the cache.
+func (c *policyCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	n := 0
+	for _, item := range c.items {
+		if !item.IsExpired(&now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Completely clear the cache
+func (c *policyCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.purgeVisitorFunc != nil {
+		for key, item := range c.items {
+			c.purgeVisitorFunc(key, item.value)
+		}
+	}
+
+	c.init()
+}
+
+func (c *policyCache) entry(key interface{}) snapshotEntry {
+	item := c.items[key]
+	return snapshotEntry{key: key, value: item.value, expiration: item.expiration}
+}
+
+// add stores an entry of a snapshot without telling the policy, it reports whether the entry fit.
+func (c *policyCache) add(e snapshotEntry) bool {
+	if c.size > 0 && len(c.items) >= c.size {
+		return false
+	}
+	if _, ok := c.items[e.key]; ok {
+		return false
+	}
+	w, err := c.weigh(e.key, e.value)
+	if err != nil || c.overweight(w) {
+		return false
+	}
+	c.addWeight(w)
+	c.items[e.key] = &policyItem{
+		clock:      c.clock,
+		key:        e.key,
+		value:      e.value,
+		expiration: e.expiration,
+		refreshAt:  c.refreshTime(),
+		weight:     w,
+	}
+	c.count(1)
+	c.expiries.set(e.key, e.expiration)
+	return true
+}
+
+// Save writes a snapshot of the cache to w, including the remaining ttl of every item.
+// The built-in policies keep their state, like the LRU order or the LFU frequencies.
+func (c *policyCache) Save(w io.Writer) error {
+	if c.lookups != nil {
+		c.mu.Lock()
+		c.flushLookups()
+		c.mu.Unlock()
+	}
+	c.mu.RLock()
+	sw := newSnapshotWriter(&c.baseCache, c.kind)
+	var write func(sw *snapshotWriter) error
+	if ps, ok := c.policy.(policySnapshot); ok {
+		write = ps.save(c.entry)
+	} else {
+		entries := make([]snapshotEntry, 0, len(c.items))
+		for key := range c.items {
+			entries = append(entries, c.entry(key))
+		}
+		write = func(sw *snapshotWriter) error {
+			return sw.writeEntries(entries)
+		}
+	}
+	c.mu.RUnlock()
+
+	if err := write(sw); err != nil {
+		return err
+	}
+	return sw.flush(w)
+}
+
+// Load replaces the contents of the cache with a snapshot written by Save.
+// If the snapshot holds more items than the cache size, the policy decides which ones are dropped.
+func (c *policyCache) Load(r io.Reader) error {
+	sr, err := readSnapshot(&c.baseCache, r, c.kind)
+	if err != nil {
+		return err
+	}
+	var restore func(add func(e snapshotEntry) bool)
+	if ps, ok := c.policy.(policySnapshot); ok {
+		if restore, err = ps.load(sr); err != nil {
+			return err
+		}
+	} else {
+		entries, err := sr.readEntries()
+		if err != nil {
+			return err
+		}
+		restore = func(add func(e snapshotEntry) bool) {
+			for _, e := range entries {
+				if add(e) {
+					c.policy.OnAdd(e.key)
+				}
+			}
+		}
+	}
+	if err := sr.done(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	restore(c.add)
+	return nil
+}
+
+type policyItem struct {
+	clock      Clock
+	key        interface{}
+	value      interface{}
+	expiration *time.Time
+	// refreshAt is when a lookup starts to reload the item, zero without RefreshAfter
+	refreshAt time.Time
+	weight    int64
+}
+
+// returns boolean value whether this item is expired or not.
+func (it *policyItem) IsExpired(now *time.Time) bool {
+	if it.expiration == nil {
+		return false
+	}
+	if now == nil {
+		t := it.clock.Now()
+		now = &t
+	}
+	return it.expiration.Before(*now)
+}
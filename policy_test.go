@@ -0,0 +1,173 @@
+package gcache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fifoPolicy evicts keys in insertion order, hits do not matter.
+type fifoPolicy struct {
+	keys   []interface{}
+	resets int
+}
+
+func (p *fifoPolicy) OnAdd(key interface{}) {
+	p.keys = append(p.keys, key)
+}
+
+func (p *fifoPolicy) OnAccess(key interface{}) {}
+
+func (p *fifoPolicy) OnRemove(key interface{}) {
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *fifoPolicy) Victim() (interface{}, bool) {
+	if len(p.keys) == 0 {
+		return nil, false
+	}
+	return p.keys[0], true
+}
+
+func (p *fifoPolicy) Reset() {
+	p.keys = nil
+	p.resets++
+}
+
+func TestCustomPolicy(t *testing.T) {
+	p := &fifoPolicy{}
+	var evicted []interface{}
+	gc := New(3).
+		Policy(p).
+		EvictedFunc(func(key, value interface{}) {
+			evicted = append(evicted, key)
+		}).
+		Build()
+	for i := 0; i < 3; i++ {
+		gc.Set(i, i)
+	}
+	gc.Get(0)
+	gc.Set(3, 3)
+	if len(evicted) != 1 || evicted[0] != 0 {
+		t.Fatalf("unexpected evictions %v", evicted)
+	}
+	gc.Remove(2)
+	if len(p.keys) != 2 || p.keys[0] != 1 || p.keys[1] != 3 {
+		t.Errorf("unexpected keys %v", p.keys)
+	}
+	st := gc.Stats()
+	if st.EvictionCount != 1 || st.RemovalCount != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	gc.Purge()
+	if len(p.keys) != 0 || p.resets != 2 {
+		t.Errorf("purge should reset the policy: %v, %v resets", p.keys, p.resets)
+	}
+}
+
+func TestCustomPolicyExpiresFirst(t *testing.T) {
+	clock := NewFakeClock()
+	gc := New(2).Policy(&fifoPolicy{}).Clock(clock).Build()
+	gc.Set("old", 1)
+	gc.SetWithExpire("short", 2, time.Second)
+	clock.Advance(2 * time.Second)
+	gc.Set("new", 3)
+	if _, err := gc.Get("old"); err != nil {
+		t.Errorf("the expired item should be evicted instead: %v", err)
+	}
+	if n := gc.Stats().ExpirationCount; n != 1 {
+		t.Errorf("%v != %v", n, 1)
+	}
+}
+
+func TestCustomPolicyLoader(t *testing.T) {
+	gc := New(2).
+		Policy(&fifoPolicy{}).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			return key.(int) * 2, nil
+		}).
+		Build()
+	for i := 0; i < 4; i++ {
+		v, err := gc.Get(i)
+		if err != nil || v != i*2 {
+			t.Fatalf("%v, %v", v, err)
+		}
+	}
+	if n := gc.Len(); n != 2 {
+		t.Errorf("%v != %v", n, 2)
+	}
+}
+
+func TestCustomPolicySaveLoad(t *testing.T) {
+	gc := New(3).Policy(&fifoPolicy{}).Build()
+	for i := 0; i < 3; i++ {
+		gc.Set(i, i)
+	}
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p := &fifoPolicy{}
+	restored := New(3).Policy(p).Build()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.keys) != 3 {
+		t.Errorf("every loaded key should be added to the policy: %v", p.keys)
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := restored.Get(i); err != nil || v != i {
+			t.Errorf("%v: %v, %v", i, v, err)
+		}
+	}
+}
+
+func TestCustomPolicyCanNotBeSharded(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Build should panic")
+		}
+	}()
+	New(10).Policy(&fifoPolicy{}).Shards(2).Build()
+}
+
+func TestTypedCustomPolicy(t *testing.T) {
+	gc := NewTyped[string, int](1).Policy(&fifoPolicy{}).Build()
+	gc.Set("a", 1)
+	gc.Set("b", 2)
+	if _, err := gc.Get("a"); err != KeyNotFoundError {
+		t.Errorf("%v != %v", err, KeyNotFoundError)
+	}
+	if v, err := gc.Get("b"); err != nil || v != 2 {
+		t.Errorf("%v, %v", v, err)
+	}
+}
+
+func TestListingIsNotALookup(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		gc := New(10).EvictType(tp).Clock(clock).Build()
+		for i := 0; i < 5; i++ {
+			gc.Set(i, i)
+		}
+		gc.SetWithExpire(5, 5, time.Second)
+		clock.Advance(time.Minute)
+		if n := gc.Len(); n != 5 {
+			t.Errorf("%s: %v != %v", tp, n, 5)
+		}
+		if n := len(gc.Keys()); n != 5 {
+			t.Errorf("%s: %v != %v", tp, n, 5)
+		}
+		if m := gc.GetALL(); len(m) != 5 || m[3] != 3 {
+			t.Errorf("%s: unexpected items %v", tp, m)
+		}
+		if n := gc.LookupCount(); n != 0 {
+			t.Errorf("%s: listing the cache counted %v lookups", tp, n)
+		}
+	}
+}
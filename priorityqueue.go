@@ -0,0 +1,149 @@
+package gcache
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// BuildPriorityQueue builds an OrderedCache which keeps its keys in a binary heap ordered by the
+// SearchCompareFunction, so DeQueue and GetTop return the smallest value.
+// EnQueue, DeQueue and Remove take O(log n). Unlike a sorted OrderedCache, setting a stored key
+// changes its value and moves it to its new priority. Keys with equal values leave in the order
+// they were added, Prepend puts a key before the ones with an equal value.
+// OrderedKeys and GetKeysAndValues sort a copy of the heap.
+func (cb *CacheBuilder) BuildPriorityQueue() OrderedCache {
+	cb.checkOrdered()
+	if cb.searchCmpFunc == nil {
+		panic("gcache: priority queue needs a SearchCompareFunction")
+	}
+	return newOrderedCache(cb, newHeapIndex(cb.searchCmpFunc))
+}
+
+type heapNode struct {
+	key   interface{}
+	value interface{}
+	// seq breaks ties between equal values, pushFront uses negative numbers.
+	seq   int64
+	index int
+}
+
+// nodeHeap implements heap.Interface.
+type nodeHeap struct {
+	cmp   SearchCompareFunction
+	nodes []*heapNode
+}
+
+func (h *nodeHeap) Len() int {
+	return len(h.nodes)
+}
+
+func (h *nodeHeap) Less(i, j int) bool {
+	a, b := h.nodes[i], h.nodes[j]
+	if c := h.cmp(a.value, b.value); c != 0 {
+		return c < 0
+	}
+	return a.seq < b.seq
+}
+
+func (h *nodeHeap) Swap(i, j int) {
+	h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i]
+	h.nodes[i].index = i
+	h.nodes[j].index = j
+}
+
+func (h *nodeHeap) Push(x interface{}) {
+	n := x.(*heapNode)
+	n.index = len(h.nodes)
+	h.nodes = append(h.nodes, n)
+}
+
+func (h *nodeHeap) Pop() interface{} {
+	last := len(h.nodes) - 1
+	n := h.nodes[last]
+	h.nodes[last] = nil
+	h.nodes = h.nodes[:last]
+	return n
+}
+
+// heapIndex is an indexed binary heap, the map finds the node of a key for remove and update.
+type heapIndex struct {
+	heap     nodeHeap
+	nodes    map[interface{}]*heapNode
+	backSeq  int64
+	frontSeq int64
+}
+
+func newHeapIndex(cmp SearchCompareFunction) *heapIndex {
+	h := &heapIndex{heap: nodeHeap{cmp: cmp}}
+	h.reset()
+	return h
+}
+
+func (h *heapIndex) add(key, value interface{}, seq int64) {
+	if n, ok := h.nodes[key]; ok {
+		n.value = value
+		n.seq = seq
+		heap.Fix(&h.heap, n.index)
+		return
+	}
+	n := &heapNode{key: key, value: value, seq: seq}
+	h.nodes[key] = n
+	heap.Push(&h.heap, n)
+}
+
+func (h *heapIndex) push(key, value interface{}) {
+	h.backSeq++
+	h.add(key, value, h.backSeq)
+}
+
+func (h *heapIndex) pushFront(key, value interface{}) {
+	h.frontSeq--
+	h.add(key, value, h.frontSeq)
+}
+
+func (h *heapIndex) update(key, value interface{}) {
+	if n, ok := h.nodes[key]; ok {
+		n.value = value
+		heap.Fix(&h.heap, n.index)
+	}
+}
+
+func (h *heapIndex) remove(key interface{}) bool {
+	n, ok := h.nodes[key]
+	if !ok {
+		return false
+	}
+	delete(h.nodes, key)
+	heap.Remove(&h.heap, n.index)
+	return true
+}
+
+func (h *heapIndex) front() (interface{}, bool) {
+	if len(h.heap.nodes) == 0 {
+		return nil, false
+	}
+	return h.heap.nodes[0].key, true
+}
+
+// ascend walks a sorted copy of the heap.
+func (h *heapIndex) ascend(fn func(key interface{}) bool) {
+	nodes := make([]*heapNode, len(h.heap.nodes))
+	copy(nodes, h.heap.nodes)
+	sorted := &nodeHeap{cmp: h.heap.cmp, nodes: nodes}
+	sort.Slice(nodes, sorted.Less)
+	for _, n := range nodes {
+		if !fn(n.key) {
+			return
+		}
+	}
+}
+
+func (h *heapIndex) len() int {
+	return len(h.heap.nodes)
+}
+
+func (h *heapIndex) reset() {
+	h.heap.nodes = nil
+	h.nodes = make(map[interface{}]*heapNode)
+	h.backSeq, h.frontSeq = 0, 0
+}
@@ -0,0 +1,200 @@
+package gcache
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestPriorityQueue(size int) OrderedCache {
+	return New(size).SearchCompareFunction(cmpInt).BuildPriorityQueue()
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	pq := newTestPriorityQueue(100)
+	values := []int{5, 3, 8, 3, 1, 9, 5}
+	for i, v := range values {
+		if err := pq.EnQueue(i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if k, v, err := pq.GetTop(); k != 4 || v != 1 || err != nil {
+		t.Errorf("expected 4:1 at the top, got %v:%v, %v", k, v, err)
+	}
+	// equal values leave in the order they were added
+	expected := []int{4, 1, 3, 0, 6, 2, 5}
+	for _, key := range expected {
+		k, _, err := pq.DeQueue()
+		if k != key || err != nil {
+			t.Fatalf("expected %d, got %v, %v", key, k, err)
+		}
+	}
+	if _, _, err := pq.DeQueue(); err != EmptyErr {
+		t.Errorf("expected EmptyErr, got %v", err)
+	}
+}
+
+func TestPriorityQueueUpdate(t *testing.T) {
+	pq := newTestPriorityQueue(100)
+	for i := 0; i < 5; i++ {
+		pq.EnQueue(i, i*10)
+	}
+	if err := pq.EnQueue(4, -1); err != nil {
+		t.Fatal(err)
+	}
+	pq.EnQueue(0, 25)
+	if keys := pq.OrderedKeys(); !equalKeys(keys, 4, 1, 2, 0, 3) {
+		t.Errorf("unexpected order %v", keys)
+	}
+	if v, _ := pq.Get(4); v != -1 {
+		t.Errorf("expected -1, got %v", v)
+	}
+	if pq.Len() != 5 {
+		t.Errorf("expected 5 items, got %d", pq.Len())
+	}
+}
+
+func TestPriorityQueueRemove(t *testing.T) {
+	pq := newTestPriorityQueue(100)
+	for i := 0; i < 10; i++ {
+		pq.EnQueue(i, i)
+	}
+	if !pq.Remove(0) || !pq.Remove(5) || pq.Remove(5) {
+		t.Error("unexpected result of Remove")
+	}
+	keys, _, err := pq.DeQueueBatch(3)
+	if err != nil || !equalKeys(keys, 1, 2, 3) {
+		t.Errorf("expected 1, 2 and 3, got %v, %v", keys, err)
+	}
+	if keys := pq.OrderedKeys(); !equalKeys(keys, 4, 6, 7, 8, 9) {
+		t.Errorf("unexpected order %v", keys)
+	}
+}
+
+func TestPriorityQueuePrepend(t *testing.T) {
+	pq := newTestPriorityQueue(100)
+	pq.EnQueue("a", 1)
+	pq.EnQueue("b", 2)
+	pq.Prepend("c", 2)
+	pq.PrependBatch([]interface{}{"d", "e"}, []interface{}{1, 1})
+	pq.EnQueueBatch([]interface{}{"f", "g"}, []interface{}{0, 2})
+	if keys := pq.OrderedKeys(); !equalKeys(keys, "f", "d", "e", "a", "c", "b", "g") {
+		t.Errorf("unexpected order %v", keys)
+	}
+}
+
+func TestPriorityQueueExpiration(t *testing.T) {
+	clock := NewFakeClock()
+	var evicted []interface{}
+	pq := New(3).
+		Clock(clock).
+		SearchCompareFunction(cmpInt).
+		EvictedFunc(func(key, value interface{}) {
+			evicted = append(evicted, key)
+		}).
+		BuildPriorityQueue()
+	pq.EnQueue(1, 1)
+	pq.EnQueue(2, 2)
+	pq.EnQueue(3, 3)
+	pq.EnQueue(4, 4)
+	if !equalKeys(evicted, 1) {
+		t.Errorf("expected the first key to be evicted, got %v", evicted)
+	}
+
+	pq = New(10).Clock(clock).Expiration(time.Second).SearchCompareFunction(cmpInt).BuildPriorityQueue()
+	pq.EnQueue(1, 1)
+	clock.Advance(2 * time.Second)
+	pq.EnQueue(2, 2)
+	if k, _, err := pq.DeQueue(); k != 2 || err != nil {
+		t.Errorf("expected the expired key to be skipped, got %v, %v", k, err)
+	}
+	if st := pq.Stats(); st.ExpirationCount != 1 {
+		t.Errorf("expected 1 expiration, got %d", st.ExpirationCount)
+	}
+}
+
+func TestPriorityQueueRandom(t *testing.T) {
+	pq := newTestPriorityQueue(10000)
+	r := rand.New(rand.NewSource(1))
+	values := make(map[int]int)
+	for i := 0; i < 2000; i++ {
+		k := r.Intn(500)
+		switch r.Intn(3) {
+		case 0:
+			pq.Remove(k)
+			delete(values, k)
+		default:
+			v := r.Intn(1000)
+			pq.EnQueue(k, v)
+			values[k] = v
+		}
+	}
+	var sorted []int
+	for _, v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Ints(sorted)
+	for i, want := range sorted {
+		_, v, err := pq.DeQueue()
+		if err != nil || v != want {
+			t.Fatalf("%d: expected %d, got %v, %v", i, want, v, err)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Errorf("expected an empty queue, got %d", pq.Len())
+	}
+}
+
+func TestPriorityQueueSnapshot(t *testing.T) {
+	pq := newTestPriorityQueue(100)
+	for i, v := range []int{3, 1, 2} {
+		pq.EnQueue(i, v)
+	}
+	var buf bytes.Buffer
+	if err := pq.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestPriorityQueue(100)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.OrderedKeys(); !equalKeys(keys, 1, 2, 0) {
+		t.Errorf("unexpected order %v", keys)
+	}
+}
+
+func TestPriorityQueueNeedsCompareFunction(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New(10).BuildPriorityQueue()
+}
+
+func TestTypedPriorityQueue(t *testing.T) {
+	pq := NewTyped[string, int](10).
+		SearchCompareFunction(func(a, b int) int {
+			return a - b
+		}).
+		BuildPriorityQueue()
+	pq.EnQueue("low", 10)
+	pq.EnQueue("high", 1)
+	if k, v, err := pq.DeQueue(); k != "high" || v != 1 || err != nil {
+		t.Errorf("expected high:1, got %v:%v, %v", k, v, err)
+	}
+}
+
+func equalKeys(keys []interface{}, expected ...interface{}) bool {
+	if len(keys) != len(expected) {
+		return false
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// Package promexport serves the statistics of gcache caches in the Prometheus text exposition format.
+//
+//	exp := promexport.New()
+//	exp.Register("users", usersCache)
+//	http.Handle("/metrics", exp)
+package promexport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bluele/gcache"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source is the part of a cache the exporter reads. It is implemented by
+// gcache.Cache, gcache.OrderedCache and the typed caches.
+// Len is read on every scrape, so it must not count as a lookup in the stats.
+type Source interface {
+	Stats() gcache.StatsSnapshot
+	Len() int
+	Capacity() int
+}
+
+// queue is implemented by ordered caches, their queue depth is exported too.
+type queue interface {
+	QueueLen() int
+}
+
+type source struct {
+	name string
+	src  Source
+}
+
+// Exporter is an http.Handler which writes the metrics of every registered cache.
+type Exporter struct {
+	mu      sync.RWMutex
+	sources []source
+}
+
+// New returns an Exporter without any cache.
+func New() *Exporter {
+	return &Exporter{}
+}
+
+// Register adds a cache, its metrics carry name in the cache label.
+// Registering a name again replaces the previous cache.
+func (e *Exporter) Register(name string, src Source) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.sources {
+		if e.sources[i].name == name {
+			e.sources[i].src = src
+			return
+		}
+	}
+	e.sources = append(e.sources, source{name: name, src: src})
+}
+
+// Unregister removes the cache registered under name.
+func (e *Exporter) Unregister(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.sources {
+		if e.sources[i].name == name {
+			e.sources = append(e.sources[:i], e.sources[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	e.WriteTo(w)
+}
+
+type sample struct {
+	labels string
+	value  string
+}
+
+type family struct {
+	name  string
+	help  string
+	kind  string
+	value func(st gcache.StatsSnapshot, src Source) []sample
+}
+
+func counter(v uint64) []sample {
+	return []sample{{value: fmt.Sprint(v)}}
+}
+
+func gauge(v int64) []sample {
+	return []sample{{value: fmt.Sprint(v)}}
+}
+
+var families = []family{
+	{"gcache_hits_total", "Number of lookups which found a value.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample { return counter(st.HitCount) }},
+	{"gcache_misses_total", "Number of lookups which found no value.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample { return counter(st.MissCount) }},
+	{"gcache_negative_hits_total", "Number of lookups answered with a cached loader error.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample { return counter(st.NegativeHitCount) }},
+	{"gcache_removals_total", "Number of items which left the cache, by cause.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample {
+			return []sample{
+				{`cause="evicted"`, fmt.Sprint(st.EvictionCount)},
+				{`cause="expired"`, fmt.Sprint(st.ExpirationCount)},
+				{`cause="removed"`, fmt.Sprint(st.RemovalCount)},
+			}
+		}},
+	{"gcache_loads_total", "Number of finished loader calls, by result.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample {
+			return []sample{
+				{`result="success"`, fmt.Sprint(st.LoadSuccessCount)},
+				{`result="error"`, fmt.Sprint(st.LoadErrorCount)},
+				{`result="panic"`, fmt.Sprint(st.LoaderPanicCount)},
+			}
+		}},
+	{"gcache_load_duration_seconds_total", "Time spent in the loader.", "counter",
+		func(st gcache.StatsSnapshot, _ Source) []sample {
+			return []sample{{value: fmt.Sprint(st.TotalLoadTime.Seconds())}}
+		}},
+	{"gcache_load_duration_seconds_max", "Longest loader call.", "gauge",
+		func(st gcache.StatsSnapshot, _ Source) []sample {
+			return []sample{{value: fmt.Sprint(st.MaxLoadTime.Seconds())}}
+		}},
+	{"gcache_loads_in_flight", "Number of loader calls running right now.", "gauge",
+		func(st gcache.StatsSnapshot, _ Source) []sample { return gauge(st.InFlightLoads) }},
+	{"gcache_size", "Number of items in the cache.", "gauge",
+		func(_ gcache.StatsSnapshot, src Source) []sample { return gauge(int64(src.Len())) }},
+	{"gcache_capacity", "Maximum number of items, 0 means unlimited.", "gauge",
+		func(_ gcache.StatsSnapshot, src Source) []sample { return gauge(int64(src.Capacity())) }},
+	{"gcache_queue_depth", "Number of keys queued in an ordered cache.", "gauge",
+		func(_ gcache.StatsSnapshot, src Source) []sample {
+			if q, ok := src.(queue); ok {
+				return gauge(int64(q.QueueLen()))
+			}
+			return nil
+		}},
+}
+
+// WriteTo writes the metrics of every registered cache to w.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.RLock()
+	sources := append([]source(nil), e.sources...)
+	e.mu.RUnlock()
+
+	stats := make([]gcache.StatsSnapshot, len(sources))
+	for i, s := range sources {
+		stats[i] = s.src.Stats()
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		var lines []string
+		for i, s := range sources {
+			for _, smp := range f.value(stats[i], s.src) {
+				labels := `cache="` + escapeLabel(s.name) + `"`
+				if smp.labels != "" {
+					labels += "," + smp.labels
+				}
+				lines = append(lines, fmt.Sprintf("%s{%s} %s\n", f.name, labels, smp.value))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, l := range lines {
+			bw.WriteString(l)
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
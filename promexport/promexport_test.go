@@ -0,0 +1,117 @@
+package promexport
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bluele/gcache"
+)
+
+func TestExporter(t *testing.T) {
+	gc := gcache.New(10).LRU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key, nil
+	}).Build()
+	gc.Get(1)
+	gc.Get(1)
+	gc.Remove(1)
+
+	oc := gcache.New(5).BuildOrderedCache()
+	oc.EnQueue(1, 1)
+	oc.EnQueue(2, 2)
+
+	exp := New()
+	exp.Register("users", gc)
+	exp.Register(`queue "a"`, oc)
+
+	rec := httptest.NewRecorder()
+	exp.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("%v != %v", ct, ContentType)
+	}
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE gcache_hits_total counter",
+		`gcache_hits_total{cache="users"} 1`,
+		`gcache_misses_total{cache="users"} 1`,
+		`gcache_negative_hits_total{cache="users"} 0`,
+		`gcache_removals_total{cache="users",cause="removed"} 1`,
+		`gcache_loads_total{cache="users",result="success"} 1`,
+		`gcache_loads_in_flight{cache="users"} 0`,
+		`gcache_size{cache="users"} 0`,
+		`gcache_capacity{cache="users"} 10`,
+		`gcache_size{cache="queue \"a\""} 2`,
+		`gcache_capacity{cache="queue \"a\""} 5`,
+		`gcache_queue_depth{cache="queue \"a\""} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `gcache_queue_depth{cache="users"}`) {
+		t.Error("only ordered caches have a queue depth")
+	}
+	if n := strings.Count(body, "# TYPE gcache_size gauge"); n != 1 {
+		t.Errorf("%v != %v", n, 1)
+	}
+
+	exp.Unregister("users")
+	var sb strings.Builder
+	if _, err := exp.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), `cache="users"`) {
+		t.Error("an unregistered cache should not be exported")
+	}
+}
+
+func TestExporterTypedCache(t *testing.T) {
+	tc := gcache.NewTyped[string, int](3).Build()
+	tc.Set("a", 1)
+	exp := New()
+	exp.Register("typed", tc)
+	var sb strings.Builder
+	exp.WriteTo(&sb)
+	if !strings.Contains(sb.String(), `gcache_size{cache="typed"} 1`) {
+		t.Errorf("unexpected output\n%s", sb.String())
+	}
+}
+
+func TestScrapeIsNotALookup(t *testing.T) {
+	caches := map[string]Source{
+		"lru":     gcache.New(10).LRU().Build(),
+		"tinylfu": gcache.New(10).TinyLFU().Build(),
+		"sharded": gcache.New(10).Shards(2).Build(),
+		"ordered": gcache.New(10).BuildOrderedCache(),
+	}
+	exp := New()
+	for name, src := range caches {
+		if c, ok := src.(gcache.Cache); ok {
+			c.Set(1, 1)
+			c.Set(2, 2)
+			c.Get(1)
+		} else {
+			oc := src.(gcache.OrderedCache)
+			oc.EnQueue(1, 1)
+			oc.EnQueue(2, 2)
+			oc.Get(1)
+		}
+		exp.Register(name, src)
+	}
+	for i := 0; i < 2; i++ {
+		var sb strings.Builder
+		if _, err := exp.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		for name := range caches {
+			if line := `gcache_hits_total{cache="` + name + `"} 1`; !strings.Contains(sb.String(), line+"\n") {
+				t.Errorf("scrape %d: missing %q in\n%s", i, line, sb.String())
+			}
+		}
+	}
+	for name, src := range caches {
+		if n := src.Stats().HitCount; n != 1 {
+			t.Errorf("%s: %v != %v", name, n, 1)
+		}
+	}
+}
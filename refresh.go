@@ -0,0 +1,39 @@
+package gcache
+
+import "time"
+
+// RefreshAfter makes a lookup of an item which was written more than d ago return the
+// stored value right away and reload it in the background, like GetIFPresent does for a
+// missing key. Only one reload per key runs at a time. If it fails, the stored value stays
+// until it expires and the next reload starts d later. It needs a loader and should be shorter
+// than the expiration. The ordered cache does not support it.
+func (cb *CacheBuilder) RefreshAfter(d time.Duration) *CacheBuilder {
+	cb.refreshAfter = d
+	return cb
+}
+
+// refreshTime returns when an item written now is due for a refresh, or the zero time without RefreshAfter.
+func (c *baseCache) refreshTime() time.Time {
+	if c.refreshAfter <= 0 {
+		return time.Time{}
+	}
+	return c.clock.Now().Add(c.refreshAfter)
+}
+
+// postponeRefresh moves the refresh of a stored item d further after a failed reload,
+// so the lookups which follow do not start another one right away.
+func (c *policyCache) postponeRefresh(key interface{}) {
+	if c.refreshAfter <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, ok := c.items[key]; ok && c.refreshDue(item.refreshAt) {
+		item.refreshAt = c.refreshTime()
+	}
+}
+
+// refreshDue reports whether an item with the given refresh time should be reloaded.
+func (c *baseCache) refreshDue(refreshAt time.Time) bool {
+	return !refreshAt.IsZero() && !c.clock.Now().Before(refreshAt)
+}
@@ -0,0 +1,188 @@
+package gcache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAfter(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var loads int32
+		clock := NewFakeClock()
+		gc := New(10).
+			EvictType(tp).
+			Clock(clock).
+			Expiration(time.Hour).
+			RefreshAfter(time.Minute).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				return int(atomic.AddInt32(&loads, 1)), nil
+			}).
+			Build()
+
+		if v, _ := gc.Get("a"); v != 1 {
+			t.Fatalf("%s: expected 1, got %v", tp, v)
+		}
+		clock.Advance(30 * time.Second)
+		if v, _ := gc.Get("a"); v != 1 || atomic.LoadInt32(&loads) != 1 {
+			t.Fatalf("%s: expected no refresh before RefreshAfter, got %v after %d loads", tp, v, loads)
+		}
+
+		clock.Advance(time.Minute)
+		if v, _ := gc.Get("a"); v != 1 {
+			t.Fatalf("%s: expected the stale value 1, got %v", tp, v)
+		}
+		if !waitFor(clock, 0, func() bool {
+			v, _ := gc.Get("a")
+			return v == 2
+		}) {
+			t.Fatalf("%s: timed out waiting for the refresh", tp)
+		}
+		if n := atomic.LoadInt32(&loads); n != 2 {
+			t.Errorf("%s: expected 2 loads, got %d", tp, n)
+		}
+	}
+}
+
+func TestRefreshAfterKeepsValueOnError(t *testing.T) {
+	var loads int32
+	clock := NewFakeClock()
+	gc := New(10).
+		LRU().
+		Clock(clock).
+		RefreshAfter(time.Minute).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			if atomic.AddInt32(&loads, 1) > 1 {
+				return nil, errors.New("backend down")
+			}
+			return "ok", nil
+		}).
+		Build()
+
+	gc.Get("a")
+	clock.Advance(2 * time.Minute)
+	gc.Get("a")
+	if !waitFor(clock, 0, func() bool {
+		return gc.Stats().LoadErrorCount == 1
+	}) {
+		t.Fatal("timed out waiting for the refresh")
+	}
+	if v, err := gc.Get("a"); v != "ok" || err != nil {
+		t.Errorf("expected the old value to stay, got %v, %v", v, err)
+	}
+}
+
+func TestRefreshAfterBacksOffOnError(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var loads int32
+		clock := NewFakeClock()
+		gc := New(10).
+			EvictType(tp).
+			Clock(clock).
+			RefreshAfter(time.Minute).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if atomic.AddInt32(&loads, 1) > 1 {
+					return nil, errors.New("backend down")
+				}
+				return "ok", nil
+			}).
+			Build()
+
+		gc.Get("a")
+		clock.Advance(2 * time.Minute)
+		gc.Get("a")
+		if !waitFor(clock, 0, func() bool {
+			return gc.Stats().LoadErrorCount == 1
+		}) {
+			t.Fatalf("%s: timed out waiting for the refresh", tp)
+		}
+		// the failed reload is not retried before RefreshAfter passed again
+		for i := 0; i < 10; i++ {
+			gc.Get("a")
+		}
+		if n := atomic.LoadInt32(&loads); n != 2 {
+			t.Errorf("%s: expected 2 loads, got %d", tp, n)
+		}
+		clock.Advance(time.Minute)
+		gc.Get("a")
+		if !waitFor(clock, 0, func() bool {
+			return gc.Stats().LoadErrorCount == 2
+		}) {
+			t.Fatalf("%s: timed out waiting for the second refresh", tp)
+		}
+		if n := atomic.LoadInt32(&loads); n != 3 {
+			t.Errorf("%s: expected 3 loads, got %d", tp, n)
+		}
+	}
+}
+
+func TestRefreshAfterOrderedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("BuildOrderedCache should panic")
+		}
+	}()
+	New(10).RefreshAfter(time.Minute).BuildOrderedCache()
+}
+
+func TestRefreshAfterSingleReload(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	clock := NewFakeClock()
+	gc := New(10).
+		SIEVE().
+		Clock(clock).
+		RefreshAfter(time.Minute).
+		LoaderFunc(func(key interface{}) (interface{}, error) {
+			if n := atomic.AddInt32(&loads, 1); n > 1 {
+				<-release
+				return int(n), nil
+			}
+			return 1, nil
+		}).
+		Build()
+
+	gc.Get("a")
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		if v, _ := gc.Get("a"); v != 1 {
+			t.Fatalf("expected the stale value while reloading, got %v", v)
+		}
+	}
+	close(release)
+	if !waitFor(clock, 0, func() bool {
+		v, _ := gc.Get("a")
+		return v == 2
+	}) {
+		t.Fatal("timed out waiting for the refresh")
+	}
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Errorf("expected a single reload, got %d loads", n)
+	}
+}
+
+func TestTypedRefreshAfter(t *testing.T) {
+	var loads int32
+	clock := NewFakeClock()
+	tc := NewTyped[string, int32](10).
+		LRU().
+		Clock(clock).
+		RefreshAfter(time.Minute).
+		LoaderFunc(func(key string) (int32, error) {
+			return atomic.AddInt32(&loads, 1), nil
+		}).
+		Build()
+
+	tc.Get("a")
+	clock.Advance(2 * time.Minute)
+	if v, _ := tc.Get("a"); v != 1 {
+		t.Fatalf("expected the stale value 1, got %v", v)
+	}
+	if !waitFor(clock, 0, func() bool {
+		v, _ := tc.Get("a")
+		return v == 2
+	}) {
+		t.Fatal("timed out waiting for the refresh")
+	}
+}
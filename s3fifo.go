@@ -0,0 +1,212 @@
+package gcache
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// s3fifoMaxFreq caps the number of hits counted for an item.
+const s3fifoMaxFreq = 3
+
+// S3FIFOCache implements S3-FIFO with three FIFO queues. New items enter a small
+// queue holding 10% of the cache. Items which were hit while they were in it move to
+// the main queue, the others are evicted and their keys are remembered in a ghost queue.
+// A remembered key which is set again goes straight to the main queue.
+// The main queue gives every item which was hit another round before evicting it.
+// A hit only increments a counter of the item, so lookups share the read lock.
+type S3FIFOCache struct {
+	policyCache
+}
+
+func newS3FIFOCache(cb *CacheBuilder) *S3FIFOCache {
+	c := &S3FIFOCache{}
+	c.setup(cb, TYPE_S3FIFO, newS3FIFOPolicy(cb.size))
+	return c
+}
+
+// s3fifoPolicy keeps the keys in the small and main queues, the newest one at the front.
+type s3fifoPolicy struct {
+	small      *list.List
+	main       *list.List
+	elements   map[interface{}]*list.Element
+	ghost      *list.List
+	ghostItems map[interface{}]*list.Element
+	smallSize  int
+	ghostSize  int
+}
+
+type s3fifoNode struct {
+	key  interface{}
+	main bool
+	// freq is incremented by lookups under the read lock, it is only accessed atomically.
+	freq int32
+}
+
+func newS3FIFOPolicy(size int) *s3fifoPolicy {
+	p := &s3fifoPolicy{smallSize: size / 10}
+	if p.smallSize < 1 {
+		p.smallSize = 1
+	}
+	p.ghostSize = size - p.smallSize
+	if p.ghostSize < 1 {
+		p.ghostSize = 1
+	}
+	return p
+}
+
+func (p *s3fifoPolicy) queue(n *s3fifoNode) *list.List {
+	if n.main {
+		return p.main
+	}
+	return p.small
+}
+
+// OnAdd puts key into the small queue, or into the main queue if it is remembered in the ghost queue.
+func (p *s3fifoPolicy) OnAdd(key interface{}) {
+	n := &s3fifoNode{key: key}
+	if g, ok := p.ghostItems[key]; ok {
+		p.ghost.Remove(g)
+		delete(p.ghostItems, key)
+		n.main = true
+	}
+	p.elements[key] = p.queue(n).PushFront(n)
+}
+
+// OnAccess counts a hit on key, up to s3fifoMaxFreq. It runs under the read lock.
+func (p *s3fifoPolicy) OnAccess(key interface{}) {
+	e, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	n := e.Value.(*s3fifoNode)
+	for {
+		f := atomic.LoadInt32(&n.freq)
+		if f >= s3fifoMaxFreq || atomic.CompareAndSwapInt32(&n.freq, f, f+1) {
+			return
+		}
+	}
+}
+
+func (p *s3fifoPolicy) OnRemove(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		p.queue(e.Value.(*s3fifoNode)).Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim takes the oldest item of the small queue which was not hit, the ones which were hit
+// move to the main queue on the way. The evicted key is remembered in the ghost queue.
+// Otherwise it takes the oldest item of the main queue which was not hit, the ones which
+// were hit go back to the front with one hit less.
+func (p *s3fifoPolicy) Victim() (interface{}, bool) {
+	for p.small.Len() > 0 && (p.small.Len() >= p.smallSize || p.main.Len() == 0) {
+		e := p.small.Back()
+		n := e.Value.(*s3fifoNode)
+		if atomic.LoadInt32(&n.freq) > 0 {
+			p.small.Remove(e)
+			n.main = true
+			atomic.StoreInt32(&n.freq, 0)
+			p.elements[n.key] = p.main.PushFront(n)
+			continue
+		}
+		p.remember(n.key)
+		return n.key, true
+	}
+	for e := p.main.Back(); e != nil; e = p.main.Back() {
+		n := e.Value.(*s3fifoNode)
+		if atomic.LoadInt32(&n.freq) > 0 {
+			atomic.AddInt32(&n.freq, -1)
+			p.main.MoveToFront(e)
+			continue
+		}
+		return n.key, true
+	}
+	return nil, false
+}
+
+// remember adds key to the ghost queue, forgetting the oldest key if it is full.
+func (p *s3fifoPolicy) remember(key interface{}) {
+	p.ghostItems[key] = p.ghost.PushFront(key)
+	for p.ghost.Len() > p.ghostSize {
+		e := p.ghost.Back()
+		p.ghost.Remove(e)
+		delete(p.ghostItems, e.Value)
+	}
+}
+
+func (p *s3fifoPolicy) Reset() {
+	p.small = list.New()
+	p.main = list.New()
+	p.elements = make(map[interface{}]*list.Element)
+	p.ghost = list.New()
+	p.ghostItems = make(map[interface{}]*list.Element)
+}
+
+func (p *s3fifoPolicy) passiveAccess() {}
+
+// save keeps the main, small and ghost queues and the hit counters.
+func (p *s3fifoPolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	var queues [2][]snapshotEntry
+	for i, l := range []*list.List{p.main, p.small} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			n := e.Value.(*s3fifoNode)
+			se := entry(n.key)
+			se.freq = uint64(atomic.LoadInt32(&n.freq))
+			queues[i] = append(queues[i], se)
+		}
+	}
+	ghost := make([]interface{}, 0, p.ghost.Len())
+	for e := p.ghost.Front(); e != nil; e = e.Next() {
+		ghost = append(ghost, e.Value)
+	}
+	return func(sw *snapshotWriter) error {
+		for _, entries := range queues {
+			if err := sw.writeEntries(entries); err != nil {
+				return err
+			}
+		}
+		return sw.writeKeys(ghost)
+	}
+}
+
+// load restores the queues, if the snapshot holds more items than the cache size
+// items of the small queue are dropped first.
+func (p *s3fifoPolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	main, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	small, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	ghost, err := sr.readKeys()
+	if err != nil {
+		return nil, err
+	}
+	return func(add func(e snapshotEntry) bool) {
+		for _, q := range []struct {
+			entries []snapshotEntry
+			main    bool
+		}{{main, true}, {small, false}} {
+			for _, e := range q.entries {
+				if add(e) {
+					n := &s3fifoNode{key: e.key, main: q.main, freq: int32(minInt(int(e.freq), s3fifoMaxFreq))}
+					p.elements[e.key] = p.queue(n).PushBack(n)
+				}
+			}
+		}
+		for _, key := range ghost {
+			if p.ghost.Len() >= p.ghostSize {
+				break
+			}
+			if _, ok := p.elements[key]; ok {
+				continue
+			}
+			if _, ok := p.ghostItems[key]; ok {
+				continue
+			}
+			p.ghostItems[key] = p.ghost.PushBack(key)
+		}
+	}, nil
+}
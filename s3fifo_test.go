@@ -0,0 +1,80 @@
+package gcache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestS3FIFOHitRate(t *testing.T) {
+	for name, trace := range map[string][]int{
+		"zipf":       zipfTrace(100000),
+		"zipf+scans": zipfWithScans(100000),
+	} {
+		lru := replayHitRate(New(100).LRU().Build(), trace)
+		s3fifo := replayHitRate(New(100).S3FIFO().Build(), trace)
+		t.Logf("%s: lru %.3f s3fifo %.3f", name, lru, s3fifo)
+		if s3fifo <= lru {
+			t.Errorf("%s: s3fifo hit rate %.3f should be higher than lru %.3f", name, s3fifo, lru)
+		}
+	}
+}
+
+func TestS3FIFOOneHitWonders(t *testing.T) {
+	gc := New(10).S3FIFO().Build().(*S3FIFOCache)
+	for i := 0; i < 10; i++ {
+		gc.Set(i, i)
+		gc.Get(i)
+	}
+	for i := 100; i < 200; i++ {
+		gc.Set(i, i)
+	}
+	// the items which were hit moved to the main queue, the scan only churns the small one
+	kept := 0
+	for i := 0; i < 10; i++ {
+		if _, err := gc.GetIFPresent(i); err == nil {
+			kept++
+		}
+	}
+	if kept < 9 {
+		t.Errorf("only %v items which were hit were kept", kept)
+	}
+	if gc.policy.(*s3fifoPolicy).ghost.Len() == 0 {
+		t.Error("the evicted keys should be remembered")
+	}
+}
+
+func TestS3FIFOGhostHit(t *testing.T) {
+	gc := New(10).S3FIFO().Build().(*S3FIFOCache)
+	for i := 0; i < 12; i++ {
+		gc.Set(i, i)
+	}
+	p := gc.policy.(*s3fifoPolicy)
+	if _, ok := p.ghostItems[0]; !ok {
+		t.Fatal("an evicted key should be remembered")
+	}
+	gc.Set(0, 0)
+	if n := p.elements[0].Value.(*s3fifoNode); !n.main {
+		t.Error("a remembered key should go to the main queue")
+	}
+}
+
+func TestS3FIFOConcurrentGet(t *testing.T) {
+	gc := New(64).S3FIFO().Build()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := (i * (g + 1)) % 100
+				if _, err := gc.Get(k); err == KeyNotFoundError {
+					gc.Set(k, k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := gc.Len(); n > 64 {
+		t.Errorf("%v > %v", n, 64)
+	}
+}
@@ -0,0 +1,472 @@
+package gcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+const snapshotKindSharded = "sharded"
+
+// shard is implemented by every cache which can be a part of a ShardedCache.
+type shard interface {
+	Cache
+	// itemCount returns the number of stored items, expired ones included.
+	itemCount() int
+	// evictOne removes one item following the eviction policy, it returns false if the cache is empty.
+	evictOne() bool
+	// beginMany starts a GetMany call, see newBatch.
+	beginMany(keys []interface{}) *bulkBatch
+}
+
+// ShardedCache spreads keys across independent caches, each with its own lock.
+// The size given to New is a global limit, every shard holds at most
+// ceil(size/shards) items unless ShardSize says otherwise.
+// MaxWeight is a global limit too, a shard takes any item up to MaxWeight.
+type ShardedCache struct {
+	// count is the number of items of all shards, expired ones included.
+	// It is only kept with a global limit, and comes first for the alignment of atomic access.
+	count int64
+	// weight is the weight of all shards, it is only kept with a MaxWeight.
+	weight    int64
+	maxWeight int64
+	shards    []shard
+	// size is the global limit, it is only checked when it is smaller than the sum of the shard sizes.
+	size    int
+	limited bool
+	// next is the shard trim evicts from next
+	next uint32
+	// stats counts the bulk loads of GetMany, which serve several shards at once
+	stats *stats
+	clock Clock
+}
+
+func newShardedCache(cb *CacheBuilder) *ShardedCache {
+	shardSize := cb.shardSize
+	if shardSize <= 0 && cb.size > 0 {
+		shardSize = (cb.size + cb.shards - 1) / cb.shards
+	}
+	c := &ShardedCache{
+		shards:  make([]shard, cb.shards),
+		size:    cb.size,
+		limited: cb.size > 0 && shardSize*cb.shards > cb.size,
+		stats:   &stats{},
+		clock:   cb.clock,
+	}
+	sb := *cb
+	sb.shards = 0
+	if c.limited {
+		sb.shardCount = &c.count
+	}
+	sb.size = shardSize
+	if cb.maxWeight > 0 {
+		sb.shardWeight = &c.weight
+		c.maxWeight = cb.maxWeight
+	}
+	for i := range c.shards {
+		c.shards[i] = sb.build().(shard)
+	}
+	return c
+}
+
+func (c *ShardedCache) shardFor(key interface{}) shard {
+	return c.shards[hashKey(key)%uint64(len(c.shards))]
+}
+
+// over reports whether the shards hold more items or more weight than the global limits allow.
+func (c *ShardedCache) over() bool {
+	return (c.limited && atomic.LoadInt64(&c.count) > int64(c.size)) ||
+		(c.maxWeight > 0 && atomic.LoadInt64(&c.weight) > c.maxWeight)
+}
+
+// trim evicts items until the global limits are met again. The shards take turns, the shard
+// which just grew only when the others are empty, so the item which was just stored stays.
+func (c *ShardedCache) trim(grown shard) {
+	if !c.limited && c.maxWeight <= 0 {
+		return
+	}
+	n := uint32(len(c.shards))
+	for c.over() {
+		evicted := false
+		for i := uint32(0); i < n && !evicted; i++ {
+			if s := c.shards[atomic.AddUint32(&c.next, 1)%n]; s != grown {
+				evicted = s.evictOne()
+			}
+		}
+		if !evicted && (grown == nil || !grown.evictOne()) {
+			return
+		}
+	}
+}
+
+// trimAfter runs fn against the shard of key and trims the cache.
+func (c *ShardedCache) trimAfter(key interface{}, fn func(s shard)) {
+	s := c.shardFor(key)
+	fn(s)
+	c.trim(s)
+}
+
+func (c *ShardedCache) Set(key, value interface{}) (err error) {
+	c.trimAfter(key, func(s shard) {
+		err = s.Set(key, value)
+	})
+	return err
+}
+
+func (c *ShardedCache) SetWithExpire(key, value interface{}, expiration time.Duration) (err error) {
+	c.trimAfter(key, func(s shard) {
+		err = s.SetWithExpire(key, value, expiration)
+	})
+	return err
+}
+
+func (c *ShardedCache) Get(key interface{}) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+func (c *ShardedCache) GetCtx(ctx context.Context, key interface{}) (v interface{}, err error) {
+	c.trimAfter(key, func(s shard) {
+		v, err = s.GetCtx(ctx, key)
+	})
+	return v, err
+}
+
+func (c *ShardedCache) GetIFPresent(key interface{}) (v interface{}, err error) {
+	c.trimAfter(key, func(s shard) {
+		v, err = s.GetIFPresent(key)
+	})
+	return v, err
+}
+
+// GetMany returns the values of keys which are stored or can be loaded.
+// The misses of all shards are loaded by a single BulkLoaderFunc call.
+func (c *ShardedCache) GetMany(keys []interface{}) (map[interface{}]interface{}, error) {
+	byShard := make([][]interface{}, len(c.shards))
+	for _, key := range keys {
+		i := hashKey(key) % uint64(len(c.shards))
+		byShard[i] = append(byShard[i], key)
+	}
+	var batches []*bulkBatch
+	var claimed []interface{}
+	var loader BulkLoaderFunc
+	for i, ks := range byShard {
+		if len(ks) == 0 {
+			continue
+		}
+		b := c.shards[i].beginMany(ks)
+		batches = append(batches, b)
+		if len(b.keys) > 0 {
+			claimed = append(claimed, b.keys...)
+			loader = b.c.bulkLoaderFunc
+		}
+	}
+	if loader != nil {
+		// the load serves several shards, so it is counted by the sharded cache
+		found, err := bulkLoad(c.stats, c.clock, loader, claimed)
+		for _, b := range batches {
+			if len(b.keys) > 0 {
+				b.complete(found, err)
+			}
+		}
+	}
+
+	m := make(map[interface{}]interface{}, len(keys))
+	var err error
+	for _, b := range batches {
+		values, e := b.finish()
+		for k, v := range values {
+			m[k] = v
+		}
+		if err == nil {
+			err = e
+		}
+	}
+	c.trim(nil)
+	return m, err
+}
+
+func (c *ShardedCache) GetOrSet(key, value interface{}) (actual interface{}, loaded bool, err error) {
+	c.trimAfter(key, func(s shard) {
+		actual, loaded, err = s.GetOrSet(key, value)
+	})
+	return actual, loaded, err
+}
+
+func (c *ShardedCache) SetIfAbsent(key, value interface{}) (ok bool, err error) {
+	c.trimAfter(key, func(s shard) {
+		ok, err = s.SetIfAbsent(key, value)
+	})
+	return ok, err
+}
+
+func (c *ShardedCache) Compute(key interface{}, f ComputeFunc) (v interface{}, err error) {
+	c.trimAfter(key, func(s shard) {
+		v, err = s.Compute(key, f)
+	})
+	return v, err
+}
+
+func (c *ShardedCache) CompareAndSwap(key, old, new interface{}) (bool, error) {
+	return c.shardFor(key).CompareAndSwap(key, old, new)
+}
+
+func (c *ShardedCache) get(key interface{}, onLoad bool) (interface{}, error) {
+	return c.shardFor(key).get(key, onLoad)
+}
+
+func (c *ShardedCache) GetALL() map[interface{}]interface{} {
+	m := make(map[interface{}]interface{})
+	for _, s := range c.shards {
+		for k, v := range s.GetALL() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+func (c *ShardedCache) Remove(key interface{}) bool {
+	return c.shardFor(key).Remove(key)
+}
+
+func (c *ShardedCache) Purge() {
+	for _, s := range c.shards {
+		s.Purge()
+	}
+}
+
+func (c *ShardedCache) Keys() []interface{} {
+	var keys []interface{}
+	for _, s := range c.shards {
+		keys = append(keys, s.Keys()...)
+	}
+	return keys
+}
+
+func (c *ShardedCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// Capacity returns the global limit, or the sum of the shard sizes if it is lower.
+func (c *ShardedCache) Capacity() int {
+	if c.limited {
+		return c.size
+	}
+	n := 0
+	for _, s := range c.shards {
+		n += s.Capacity()
+	}
+	return n
+}
+
+// Save writes a snapshot of every shard to w.
+// It can only be loaded into a cache with the same number of shards.
+func (c *ShardedCache) Save(w io.Writer) error {
+	sw := newSnapshotWriter(nil, snapshotKindSharded)
+	sw.writeUvarint(uint64(len(c.shards)))
+	for _, s := range c.shards {
+		var buf bytes.Buffer
+		if err := s.Save(&buf); err != nil {
+			return err
+		}
+		sw.writeBytes(buf.Bytes())
+	}
+	return sw.flush(w)
+}
+
+// Load replaces the contents of every shard with a snapshot written by Save.
+func (c *ShardedCache) Load(r io.Reader) error {
+	sr, err := readSnapshot(nil, r, snapshotKindSharded)
+	if err != nil {
+		return err
+	}
+	n, err := sr.readUvarint()
+	if err != nil {
+		return err
+	}
+	if n != uint64(len(c.shards)) {
+		return fmt.Errorf("snapshot has %d shards, the cache has %d", n, len(c.shards))
+	}
+	blobs := make([][]byte, n)
+	for i := range blobs {
+		if blobs[i], err = sr.readBytes(); err != nil {
+			return err
+		}
+	}
+	if err := sr.done(); err != nil {
+		return err
+	}
+	for i, s := range c.shards {
+		if err := s.Load(bytes.NewReader(blobs[i])); err != nil {
+			return err
+		}
+	}
+	c.trim(nil)
+	return nil
+}
+
+// Close stops the janitors of all shards and flushes the TraceRecorder, it returns the first error.
+func (c *ShardedCache) Close() (err error) {
+	for _, s := range c.shards {
+		if e := s.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// HitCount returns hit count of all shards
+func (c *ShardedCache) HitCount() uint64 {
+	var n uint64
+	for _, s := range c.shards {
+		n += s.HitCount()
+	}
+	return n
+}
+
+// MissCount returns miss count of all shards
+func (c *ShardedCache) MissCount() uint64 {
+	var n uint64
+	for _, s := range c.shards {
+		n += s.MissCount()
+	}
+	return n
+}
+
+// LookupCount returns lookup count of all shards
+func (c *ShardedCache) LookupCount() uint64 {
+	return c.HitCount() + c.MissCount()
+}
+
+// HitRate returns rate for cache hitting of all shards
+func (c *ShardedCache) HitRate() float64 {
+	hc, mc := c.HitCount(), c.MissCount()
+	total := hc + mc
+	if total == 0 {
+		return 0.0
+	}
+	return float64(hc) / float64(total)
+}
+
+// Stats returns the counters of all shards and the bulk loads of GetMany added up,
+// MaxLoadTime is the largest of them.
+func (c *ShardedCache) Stats() StatsSnapshot {
+	st := c.stats.Stats()
+	for _, s := range c.shards {
+		ss := s.Stats()
+		st.HitCount += ss.HitCount
+		st.MissCount += ss.MissCount
+		st.EvictionCount += ss.EvictionCount
+		st.ExpirationCount += ss.ExpirationCount
+		st.RemovalCount += ss.RemovalCount
+		st.LoadSuccessCount += ss.LoadSuccessCount
+		st.LoadErrorCount += ss.LoadErrorCount
+		st.LoaderPanicCount += ss.LoaderPanicCount
+		st.TotalLoadTime += ss.TotalLoadTime
+		if ss.MaxLoadTime > st.MaxLoadTime {
+			st.MaxLoadTime = ss.MaxLoadTime
+		}
+		st.InFlightLoads += ss.InFlightLoads
+		st.NegativeHitCount += ss.NegativeHitCount
+	}
+	return st
+}
+
+// ResetStats resets the counters of all shards
+func (c *ShardedCache) ResetStats() {
+	c.stats.ResetStats()
+	for _, s := range c.shards {
+		s.ResetStats()
+	}
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// hashKey returns a FNV-1a hash of key, which stays the same between processes
+// for strings, numbers and other keys with a stable fmt representation.
+// Pointers and channels are hashed by address, like they are compared.
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return hashUint64(uint64(k))
+	case int8:
+		return hashUint64(uint64(k))
+	case int16:
+		return hashUint64(uint64(k))
+	case int32:
+		return hashUint64(uint64(k))
+	case int64:
+		return hashUint64(uint64(k))
+	case uint:
+		return hashUint64(uint64(k))
+	case uint8:
+		return hashUint64(uint64(k))
+	case uint16:
+		return hashUint64(uint64(k))
+	case uint32:
+		return hashUint64(uint64(k))
+	case uint64:
+		return hashUint64(k)
+	case uintptr:
+		return hashUint64(uint64(k))
+	case float32:
+		return hashUint64(uint64(math.Float32bits(k)))
+	case float64:
+		return hashUint64(math.Float64bits(k))
+	case bool:
+		if k {
+			return hashUint64(1)
+		}
+		return hashUint64(0)
+	default:
+		// fmt prints what a pointer points to, which may change while it is a key
+		switch v := reflect.ValueOf(key); v.Kind() {
+		case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+			return mixAddress(uint64(v.Pointer()))
+		}
+		return hashString(fmt.Sprintf("%T:%v", key, key))
+	}
+}
+
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func hashUint64(x uint64) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < 8; i++ {
+		h ^= x & 0xff
+		h *= fnvPrime64
+		x >>= 8
+	}
+	return h
+}
+
+// mixAddress hashes a pointer, the addresses of nearby objects only differ in a few
+// middle bits, which FNV-1a would leave in the high bits of the hash.
+func mixAddress(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,146 @@
+package gcache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedCacheGet(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		gc := New(1000).EvictType(tp).Shards(8).Build()
+		if _, ok := gc.(*ShardedCache); !ok {
+			t.Fatalf("%s: expected a sharded cache, got %T", tp, gc)
+		}
+		// keys are not spread perfectly even, so stay below the size of a shard
+		testSetCache(t, gc, 500)
+		testGetCache(t, gc, 500)
+		if gc.HitCount() != 500 {
+			t.Errorf("%s: %v != %v", tp, gc.HitCount(), 500)
+		}
+		if gc.Len() != 500 {
+			t.Errorf("%s: %v != %v", tp, gc.Len(), 500)
+		}
+	}
+}
+
+func TestShardedCacheLoader(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var loads int64
+		gc := New(1000).EvictType(tp).Shards(4).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				atomic.AddInt64(&loads, 1)
+				return loader(key)
+			}).
+			Build()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				testGetCache(t, gc, 100)
+			}()
+		}
+		wg.Wait()
+		if loads != 100 {
+			t.Errorf("%s: %v != %v", tp, loads, 100)
+		}
+	}
+}
+
+func TestShardedCacheGlobalLimit(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		// every shard may hold 50 items, but the whole cache only 100
+		gc := New(100).EvictType(tp).Shards(4).ShardSize(50).Build().(*ShardedCache)
+		for i := 0; i < 1000; i++ {
+			gc.Set(i, i)
+		}
+		total := 0
+		for _, s := range gc.shards {
+			n := s.itemCount()
+			if n > 50 {
+				t.Errorf("%s: shard holds %v items, more than its limit", tp, n)
+			}
+			total += n
+		}
+		if total > 100 {
+			t.Errorf("%s: cache holds %v items, more than the global limit", tp, total)
+		}
+	}
+}
+
+func TestShardedCacheGlobalLimitKeepsNewItem(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		gc := New(8).EvictType(tp).Shards(4).ShardSize(8).Build()
+		for i := 0; i < 100; i++ {
+			gc.Set(i, i)
+			if _, err := gc.GetIFPresent(i); err != nil {
+				t.Fatalf("%s: the item which was just set was evicted: %v", tp, err)
+			}
+		}
+		if n := gc.Len(); n != 8 {
+			t.Errorf("%s: %v != %v", tp, n, 8)
+		}
+		gc.Remove(99)
+		if n := gc.(*ShardedCache).count; n != 7 {
+			t.Errorf("%s: count %v != %v", tp, n, 7)
+		}
+		gc.Purge()
+		if n := gc.(*ShardedCache).count; n != 0 {
+			t.Errorf("%s: count %v != %v", tp, n, 0)
+		}
+	}
+}
+
+func TestShardedCacheSaveLoad(t *testing.T) {
+	gc := New(100).LRU().Shards(4).Build()
+	for i := 0; i < 50; i++ {
+		gc.Set(fmt.Sprintf("key-%d", i), i)
+	}
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(100).LRU().Shards(4).Build()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if v, err := restored.Get(fmt.Sprintf("key-%d", i)); err != nil || v != i {
+			t.Errorf("%v != %v (%v)", v, i, err)
+		}
+	}
+}
+
+func TestHashKeyIsStable(t *testing.T) {
+	if hashKey("key") != hashKey("key") || hashKey(42) != hashKey(42) {
+		t.Error("hashKey should be deterministic")
+	}
+	if hashKey(1) == hashKey(2) {
+		t.Error("different keys should not collide here")
+	}
+}
+
+func TestShardedCacheMutatedPointerKey(t *testing.T) {
+	type user struct{ name string }
+	gc := New(1000).Shards(16).Build()
+	keys := make([]*user, 20)
+	for i := range keys {
+		keys[i] = &user{name: fmt.Sprint(i)}
+		gc.Set(keys[i], i)
+	}
+	for i, k := range keys {
+		k.name = "renamed " + k.name
+		if v, err := gc.Get(k); err != nil || v != i {
+			t.Errorf("%v != %v (%v)", v, i, err)
+		}
+		if !gc.Remove(k) {
+			t.Errorf("%v should be removed", i)
+		}
+	}
+	if n := gc.Len(); n != 0 {
+		t.Errorf("%v != %v", n, 0)
+	}
+}
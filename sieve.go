@@ -0,0 +1,119 @@
+package gcache
+
+import (
+	"container/list"
+	"sync/atomic"
+)
+
+// SieveCache implements SIEVE. Items are kept in insertion order and a hit only
+// marks the item as visited, so lookups share the read lock. To evict, a hand
+// walks from the oldest item towards the newest one, clearing the visited marks
+// it passes, and evicts the first item which was not visited.
+type SieveCache struct {
+	policyCache
+}
+
+func newSieveCache(cb *CacheBuilder) *SieveCache {
+	c := &SieveCache{}
+	c.setup(cb, TYPE_SIEVE, &sievePolicy{})
+	return c
+}
+
+// sievePolicy keeps the keys in insertion order, the newest one at the front.
+type sievePolicy struct {
+	queue    *list.List
+	elements map[interface{}]*list.Element
+	hand     *list.Element
+}
+
+type sieveNode struct {
+	key interface{}
+	// visited is set by lookups under the read lock, it is only accessed atomically.
+	visited uint32
+}
+
+func (p *sievePolicy) OnAdd(key interface{}) {
+	p.elements[key] = p.queue.PushFront(&sieveNode{key: key})
+}
+
+// OnAccess marks key as visited, it runs under the read lock.
+func (p *sievePolicy) OnAccess(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		atomic.StoreUint32(&e.Value.(*sieveNode).visited, 1)
+	}
+}
+
+func (p *sievePolicy) OnRemove(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		if p.hand == e {
+			p.hand = e.Prev()
+		}
+		p.queue.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim moves the hand to the next item which was not visited.
+func (p *sievePolicy) Victim() (interface{}, bool) {
+	e := p.hand
+	if e == nil {
+		e = p.queue.Back()
+	}
+	if e == nil {
+		return nil, false
+	}
+	for {
+		n := e.Value.(*sieveNode)
+		if atomic.LoadUint32(&n.visited) == 0 {
+			break
+		}
+		atomic.StoreUint32(&n.visited, 0)
+		if e = e.Prev(); e == nil {
+			e = p.queue.Back()
+		}
+	}
+	p.hand = e
+	return e.Value.(*sieveNode).key, true
+}
+
+func (p *sievePolicy) Reset() {
+	p.queue = list.New()
+	p.elements = make(map[interface{}]*list.Element)
+	p.hand = nil
+}
+
+func (p *sievePolicy) passiveAccess() {}
+
+// save keeps the insertion order and the visited marks.
+// The position of the hand is not saved, it starts again at the oldest item.
+func (p *sievePolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	entries := make([]snapshotEntry, 0, p.queue.Len())
+	for e := p.queue.Front(); e != nil; e = e.Next() {
+		n := e.Value.(*sieveNode)
+		se := entry(n.key)
+		se.freq = uint64(atomic.LoadUint32(&n.visited))
+		entries = append(entries, se)
+	}
+	return func(sw *snapshotWriter) error {
+		return sw.writeEntries(entries)
+	}
+}
+
+// load restores the insertion order, if the snapshot holds more items than the cache size the oldest ones are dropped.
+func (p *sievePolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	entries, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	return func(add func(e snapshotEntry) bool) {
+		for _, e := range entries {
+			if add(e) {
+				n := &sieveNode{key: e.key}
+				if e.freq > 0 {
+					n.visited = 1
+				}
+				p.elements[e.key] = p.queue.PushBack(n)
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,75 @@
+package gcache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSieveHitRate(t *testing.T) {
+	for name, trace := range map[string][]int{
+		"zipf":       zipfTrace(100000),
+		"zipf+scans": zipfWithScans(100000),
+	} {
+		lru := replayHitRate(New(100).LRU().Build(), trace)
+		sieve := replayHitRate(New(100).SIEVE().Build(), trace)
+		t.Logf("%s: lru %.3f sieve %.3f", name, lru, sieve)
+		if sieve <= lru {
+			t.Errorf("%s: sieve hit rate %.3f should be higher than lru %.3f", name, sieve, lru)
+		}
+	}
+}
+
+func TestSieveEvictsUnvisited(t *testing.T) {
+	gc := New(3).SIEVE().Build()
+	gc.Set(0, 0)
+	gc.Set(1, 1)
+	gc.Set(2, 2)
+	gc.Get(0)
+	gc.Set(3, 3)
+	// 0 is the oldest item, but it was visited
+	if _, err := gc.GetIFPresent(1); err != KeyNotFoundError {
+		t.Errorf("%v != %v", err, KeyNotFoundError)
+	}
+	for _, k := range []int{0, 2, 3} {
+		if _, err := gc.GetIFPresent(k); err != nil {
+			t.Errorf("%v: %v", k, err)
+		}
+	}
+}
+
+func TestSieveHandSurvivesRemove(t *testing.T) {
+	gc := New(3).SIEVE().Build().(*SieveCache)
+	for i := 0; i < 3; i++ {
+		gc.Set(i, i)
+		gc.Get(i)
+	}
+	gc.Set(3, 3)
+	gc.Remove(gc.policy.(*sievePolicy).hand.Value.(*sieveNode).key)
+	for i := 4; i < 10; i++ {
+		gc.Set(i, i)
+	}
+	if n := gc.Len(); n != 3 {
+		t.Errorf("%v != %v", n, 3)
+	}
+}
+
+func TestSieveConcurrentGet(t *testing.T) {
+	gc := New(64).SIEVE().Build()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := (i * (g + 1)) % 100
+				if _, err := gc.Get(k); err == KeyNotFoundError {
+					gc.Set(k, k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := gc.Len(); n > 64 {
+		t.Errorf("%v > %v", n, 64)
+	}
+}
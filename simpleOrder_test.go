@@ -20,15 +20,13 @@ func TestSimpleOrderedCache_Remove(t *testing.T) {
 	for i := 0; i < 1000; i++ {
 		c.EnQueue(i, fmt.Sprintf("%d", i))
 	}
-	t.Log(c.Len(), c.orderedKeys)
-	//index := []int{0}
-	//c.removeKeysByIndex(index)
-	t.Log(len(c.orderedKeys), c.orderedKeys)
+	t.Log(c.Len(), c.OrderedKeys())
+	t.Log(c.QueueLen(), c.OrderedKeys())
 	total := c.Len()
 	for i := 0; i < total; i++ {
 		c.DeQueue()
 	}
-	t.Log(c.Len(), c.orderedKeys)
+	t.Log(c.Len(), c.OrderedKeys())
 }
 
 func TestSimpleOrderedCache_EnQueueBatch(t *testing.T) {
@@ -44,15 +42,15 @@ func TestSimpleOrderedCache_EnQueueBatch(t *testing.T) {
 	}
 	t.Log(len(keys), len(values))
 	c.EnQueueBatch(keys, values)
-	t.Log(c.Len(), c.orderedKeys)
+	t.Log(c.Len(), c.OrderedKeys())
 	total := c.Len()
 	for i := 0; i < total/2; i++ {
 		c.DeQueue()
 	}
-	t.Log(c.Len(), c.orderedKeys)
+	t.Log(c.Len(), c.OrderedKeys())
 	keys, values, err := c.DeQueueBatch(40)
 	t.Log(len(keys), len(values), err)
-	t.Log(len(c.orderedKeys), c.orderedKeys)
+	t.Log(c.QueueLen(), c.OrderedKeys())
 	var itemKeys []int
 	for k := range c.items {
 		itemKeys = append(itemKeys, k.(int))
@@ -64,20 +62,6 @@ func TestSimpleOrderedCache_EnQueueBatch(t *testing.T) {
 	t.Log(val, err)
 }
 
-func TestRemoveByIndex(t *testing.T) {
-	c := newtestSimploOrderCache(nil)
-	var index []int
-	for i := 0; i < 100; i++ {
-		c.orderedKeys = append(c.orderedKeys, i)
-		index = append(index, i)
-	}
-	c.removeKeysByIndex(index)
-	if len(c.orderedKeys) != 0 {
-		t.Fatalf("need 0, got %d %v, %v", c.Len(), c.orderedKeys)
-	}
-}
-
-
 func TestSimpleOrderedCache_MoveFront(t *testing.T) {
 	c := newtestSimploOrderCache(nil)
 	for i := 0; i < 100; i++ {
@@ -85,9 +69,9 @@ func TestSimpleOrderedCache_MoveFront(t *testing.T) {
 		c.EnQueue(i,val)
 	}
 	err := c.MoveFront(1)
-	fmt.Println(err,c.orderedKeys)
+	fmt.Println(err,c.OrderedKeys())
 	err= c.MoveFront(10)
-	fmt.Println(err,c.orderedKeys)
+	fmt.Println(err,c.OrderedKeys())
 }
 
 func TestSimpleOrderedCache_GetKeysAndValues(t *testing.T) {
@@ -190,23 +174,14 @@ func TestSimpleCache_BatchAddFrontWithOrder(t *testing.T) {
 		c.EnQueue(i,val)
 	}
 	c.PrintValues(1)
-	c.orderedKeys = append(c.orderedKeys,54,55)
-	SliceInsert(c.orderedKeys,0,56)
-	SliceInsert(c.orderedKeys,0,57)
 	//insert at  the front
 	c.PrependBatch([]interface{}{101,102,103},[]interface{}{-4*3+1,-2*3+1,-1*3+1})
 	c.PrintValues(1)
-	c.orderedKeys = append(c.orderedKeys,58,59)
-	SliceInsert(c.orderedKeys,0,567)
-	SliceInsert(c.orderedKeys,0,577)
 	//insert at the end
 	c.PrependBatch([]interface{}{80,81,82},[]interface{}{102*3+1,103*3+1,104*3+1})
 	c.PrintValues(1)
 
 	//insert in the middle
-	c.orderedKeys = append(c.orderedKeys,50,51)
-	SliceInsert(c.orderedKeys,0,52)
-	SliceInsert(c.orderedKeys,0,53)
 	c.PrependBatch([]interface{}{30,31,32,33,34,35,36},[]interface{}{8*3+1,12*3+1,12*3+1,13*3,15*3+1,15*3+1,17*3+1})
 	c.PrintValues(1)
 	c.EnQueueBatch([]interface{}{40},[]interface{}{30})
@@ -1,7 +1,10 @@
 package gcache
 
 import (
+	"container/list"
+	"context"
 	"fmt"
+	"io"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -9,16 +12,56 @@ import (
 // SimpleOrderedCache has no clear priority for evict cache. It depends on key-value map order.
 type SimpleOrderedCache struct {
 	baseCache
-	items       map[interface{}]*simpleItem
-	orderedKeys []interface{}
+	items map[interface{}]*simpleItem
+	// waiters holds the channels of the goroutines blocked in DeQueueWait and DeQueueBatchWait, oldest first.
+	waiters list.List
+	// index keeps the keys in queue order: a linked list, a tree when the cache is sorted
+	// by the SearchCompareFunction, or the heap of a priority queue.
+	index orderIndex
+}
+
+// orderIndex keeps the keys of an ordered cache in queue order.
+// Its methods are called with the cache lock held.
+type orderIndex interface {
+	// push adds key at its position from the back of the queue.
+	push(key, value interface{})
+	// pushFront adds key at its position from the front of the queue.
+	pushFront(key, value interface{})
+	// update is called after the value of key changed, a sorted index moves key to its new position.
+	update(key, value interface{})
+	remove(key interface{}) bool
+	// front returns the first key.
+	front() (interface{}, bool)
+	// ascend calls fn for the keys in queue order until it returns false, fn must not change the index.
+	ascend(fn func(key interface{}) bool)
+	len() int
+	reset()
+}
+
+// indexKeys returns all keys of ix in queue order.
+func indexKeys(ix orderIndex) []interface{} {
+	keys := make([]interface{}, 0, ix.len())
+	ix.ascend(func(key interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
 }
 
 func newSimpleOrderedCache(cb *CacheBuilder) *SimpleOrderedCache {
-	c := &SimpleOrderedCache{}
+	if cb.searchCmpFunc != nil {
+		return newOrderedCache(cb, newTreeIndex(cb.searchCmpFunc))
+	}
+	return newOrderedCache(cb, newListIndex())
+}
+
+func newOrderedCache(cb *CacheBuilder, index orderIndex) *SimpleOrderedCache {
+	c := &SimpleOrderedCache{index: index}
 	buildCache(&c.baseCache, cb)
 
 	c.init()
 	c.loadGroup.orderedCache = c
+	c.startJanitor(cb.cleanupInterval, c.deleteExpired)
 	return c
 }
 
@@ -28,7 +71,31 @@ func (c *SimpleOrderedCache) init() {
 	} else {
 		c.items = make(map[interface{}]*simpleItem, c.size)
 	}
-	c.orderedKeys = nil
+	c.index.reset()
+	c.expiries.reset()
+	c.weight = 0
+	c.forgetErrors()
+}
+
+// replaces tells whether setting a stored key replaces its value.
+// A sorted cache keeps the first value, a priority queue moves the key to its new value.
+func (c *SimpleOrderedCache) replaces() bool {
+	_, sorted := c.index.(*treeIndex)
+	return !sorted
+}
+
+// makeRoomFor evicts items until key can be stored with weight w.
+func (c *SimpleOrderedCache) makeRoomFor(key interface{}, w int64) {
+	c.makeRoom(w, func() int64 {
+		if item, ok := c.items[key]; ok {
+			return item.weight
+		}
+		return 0
+	}, func() bool {
+		n := len(c.items)
+		c.evict(1)
+		return len(c.items) < n
+	})
 }
 
 func (c *SimpleOrderedCache) addFront(key, value interface{}) (interface{}, error) {
@@ -40,11 +107,20 @@ func (c *SimpleOrderedCache) addFront(key, value interface{}) (interface{}, erro
 		}
 	}
 
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.items[key]; !ok || c.replaces() {
+		c.makeRoomFor(key, w)
+	}
+
 	// Check for existing item
 	item, ok := c.items[key]
 	if ok {
-		if c.searchCmpFunc == nil {
+		if c.replaces() {
 			item.value = value
+			c.index.update(key, value)
 		} else {
 			//don't set again ,if using ordered insert
 			return item, fmt.Errorf("duplicated set")
@@ -62,16 +138,15 @@ func (c *SimpleOrderedCache) addFront(key, value interface{}) (interface{}, erro
 			value: value,
 		}
 		c.items[key] = item
-		if c.searchCmpFunc != nil {
-			c.insertKey(key, value, 0)
-		} else {
-			c.orderedKeys = append([]interface{}{key}, c.orderedKeys...)
-		}
+		c.index.pushFront(key, value)
 	}
+	c.weight += w - item.weight
+	item.weight = w
 
 	if c.expiration != nil {
 		t := c.clock.Now().Add(*c.expiration)
 		item.expiration = &t
+		c.expiries.set(key, &t)
 	}
 
 	if c.addedFunc != nil {
@@ -82,6 +157,12 @@ func (c *SimpleOrderedCache) addFront(key, value interface{}) (interface{}, erro
 }
 
 func (c *SimpleOrderedCache) enQueue(key, value interface{}) (interface{}, error) {
+	return c.push(key, value, c.replaces())
+}
+
+// push adds key at its position from the back of the queue, replace tells whether the value of a stored key
+// is replaced or an error is returned.
+func (c *SimpleOrderedCache) push(key, value interface{}, replace bool) (interface{}, error) {
 	var err error
 	if c.serializeFunc != nil {
 		value, err = c.serializeFunc(key, value)
@@ -90,11 +171,20 @@ func (c *SimpleOrderedCache) enQueue(key, value interface{}) (interface{}, error
 		}
 	}
 
+	w, err := c.weigh(key, value)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.items[key]; !ok || replace {
+		c.makeRoomFor(key, w)
+	}
+
 	// Check for existing item
 	item, ok := c.items[key]
 	if ok {
-		if c.searchCmpFunc == nil {
+		if replace {
 			item.value = value
+			c.index.update(key, value)
 		} else {
 			//don't set again ,if using ordered insert
 			return item, fmt.Errorf("duplicated set")
@@ -113,16 +203,15 @@ func (c *SimpleOrderedCache) enQueue(key, value interface{}) (interface{}, error
 			value: value,
 		}
 		c.items[key] = item
-		if c.searchCmpFunc != nil {
-			c.insertKey(key, value, len(c.orderedKeys))
-		} else {
-			c.orderedKeys = append(c.orderedKeys, key)
-		}
+		c.index.push(key, value)
 	}
+	c.weight += w - item.weight
+	item.weight = w
 
 	if c.expiration != nil {
 		t := c.clock.Now().Add(*c.expiration)
 		item.expiration = &t
+		c.expiries.set(key, &t)
 	}
 
 	if c.addedFunc != nil {
@@ -137,8 +226,6 @@ func (c *SimpleOrderedCache) enQueueBatch(keys []interface{}, values []interface
 		panic("len(keys) != len(values)")
 	}
 	evicted := false
-	var insertKeys []interface{}
-	var insertValues []interface{}
 	for i, key := range keys {
 		value := values[i]
 		var err error
@@ -149,11 +236,22 @@ func (c *SimpleOrderedCache) enQueueBatch(keys []interface{}, values []interface
 			}
 		}
 
+		w, err := c.weigh(key, value)
+		if err != nil {
+			return err
+		}
+		if _, ok := c.items[key]; !ok || c.replaces() {
+			c.makeRoomFor(key, w)
+		}
+
 		// Check for existing item
 		item, ok := c.items[key]
 		if ok {
-			if c.searchCmpFunc == nil {
+			if c.replaces() {
 				item.value = value
+				c.weight += w - item.weight
+				item.weight = w
+				c.index.update(key, value)
 			} else {
 				//don't set again ,if using ordered insert
 			}
@@ -169,186 +267,28 @@ func (c *SimpleOrderedCache) enQueueBatch(keys []interface{}, values []interface
 				}
 			}
 			item = &simpleItem{
-				clock: c.clock,
-				value: value,
+				clock:  c.clock,
+				value:  value,
+				weight: w,
 			}
+			c.weight += w
 			c.items[key] = item
-			if c.searchCmpFunc != nil {
-				insertKeys = append(insertKeys, key)
-				insertValues = append(insertValues, value)
-			} else {
-				c.orderedKeys = append(c.orderedKeys, key)
-			}
+			c.index.push(key, value)
 		}
 
 		if c.expiration != nil {
 			t := c.clock.Now().Add(*c.expiration)
 			item.expiration = &t
+			c.expiries.set(key, &t)
 		}
 
 		if c.addedFunc != nil {
 			c.addedFunc(key, value)
 		}
 	}
-	if c.searchCmpFunc != nil {
-		c.insertKeys(insertKeys, insertValues, len(c.orderedKeys))
-	}
 	return nil
 }
 
-//insertKeys  required  keys and values are sorted , otherwise never call this function
-func (c *SimpleOrderedCache) insertKeys(keys []interface{}, values []interface{}, suggestedAt int) {
-	if c.searchCmpFunc == nil {
-		panic("index func is nil")
-	}
-	if len(keys) ==0 {
-		return
-	}
-	if suggestedAt < 0 || suggestedAt > len(c.orderedKeys) || len(keys) != len(values) {
-		panic(fmt.Sprintf("suggestedAt %d out of range ,len: %d, len keys %d, len values:%d",
-			suggestedAt, len(c.orderedKeys), len(keys), len(values)))
-	}
-	if len(c.orderedKeys) == 0 {
-		log.WithField("keys ",keys).Trace("ordered keys is nil ")
-		c.orderedKeys = keys
-		return
-	}
-	if len(keys) == 1 {
-		c.insertKey(keys[0], values[0], suggestedAt)
-		return
-	}
-	// if all item are smaller than first cache item, insert all of them to front
-	first := 0
-	ok := false
-	for !ok {
-		k := c.orderedKeys[first]
-		item, ok := c.items[k]
-		if ok {
-			if c.searchCmpFunc(values[len(values)-1], item.value) <= 0 {
-				newKeys := keys
-				c.orderedKeys = append(newKeys, c.orderedKeys[first:]...)
-				log.WithField("keys",keys).WithField("orderedkeys ",len(c.orderedKeys)).Trace("append keys to front")
-				return
-			}
-			break
-		}
-		//may be expired
-		first++
-		if first == len(c.orderedKeys) {
-			c.orderedKeys = keys
-			log.WithField("keys",keys).WithField("orderedkeys ",len(c.orderedKeys)).Trace("append keys to front")
-			return
-		}
-	}
-	//if all item are bigger than last item ,insert all of them to end
-	last := len(c.orderedKeys) - 1
-	ok = false
-	for !ok {
-		k := c.orderedKeys[last]
-		item, ok := c.items[k]
-		if ok {
-			if c.searchCmpFunc(values[0], item.value) >= 0 {
-				if last != len(c.orderedKeys)-1 {
-					c.orderedKeys = append(c.orderedKeys[:last+1])
-				}
-				c.orderedKeys = append(c.orderedKeys, keys...)
-				log.WithField("keys",keys).WithField("orderedkeys ",len(c.orderedKeys)).Trace("append keys to last")
-				return
-			}
-			break
-		}
-		last--
-		if last  < 0 {
-			c.orderedKeys = keys
-			log.WithField("keys",keys).WithField("orderedkeys ",len(c.orderedKeys)).Trace("append keys to last")
-			return
-		}
-	}
-
-	//batch search a small sorted slice from a large sorted slice
-	to := len(c.orderedKeys)
-	from := 0
-	insertIndex := make([]int, len(keys))
-	i := 0
-	j := len(keys) - 1
-	for i <= j {
-		smallValue := values[i]
-		smallIndex := c.searchFrom(to, from, smallValue)
-		insertIndex[i] = smallIndex
-		from = smallIndex
-		bigValue := values[j]
-		if c.searchCmpFunc(smallValue, bigValue) == 0 {
-			for i <= j {
-				insertIndex[j] = smallIndex
-				insertIndex[i] = smallIndex
-				j--
-				i++
-			}
-		}
-		bigIndex := c.searchFrom(to, from, bigValue)
-		insertIndex[j] = bigIndex
-		if bigIndex < len(c.orderedKeys) {
-			to = bigIndex + 1
-		}
-		j--
-		i++
-	}
-	//then insert the values
-	c.insertKeysByIndex(insertIndex, keys)
-	log.WithFields(log.Fields{"key":keys,"values":values,"ordered keys ": len(c.orderedKeys), "suggestedAt":suggestedAt}).Trace("after insert keys")
-	return
-}
-
-func (c *SimpleOrderedCache) insertKey(key interface{}, value interface{}, suggestedAt int) {
-	if c.searchCmpFunc == nil {
-		panic("searchCmp function func is nil")
-	}
-	if len(c.orderedKeys) == 0 {
-		c.orderedKeys = append(c.orderedKeys, key)
-		log.WithField("key ",key).WithField("ordered ",len(c.orderedKeys)).Trace("ordered keys is nil")
-		return
-	}
-	if key ==nil {
-		return
-	}
-	if suggestedAt < 0 || suggestedAt > len(c.orderedKeys) {
-		panic(fmt.Sprintf("suggestedAt %d out of range ,len: %d", suggestedAt, len(c.orderedKeys)))
-	}
-	//suggested index just support front and end
-	if suggestedAt == len(c.orderedKeys) {
-		suggestedAt--
-		k := c.orderedKeys[suggestedAt]
-		item, ok := c.items[k]
-		if ok {
-			val := item.value
-			//suggested at insert front
-			if c.searchCmpFunc(value, val) >= 0 {
-				c.orderedKeys = append(c.orderedKeys, key)
-				log.WithField("key ",key).WithField("ordered ",len(c.orderedKeys)).Trace("insert to end")
-				return
-			}
-		}
-	} else if suggestedAt == 0 {
-		k := c.orderedKeys[suggestedAt]
-		item, ok := c.items[k]
-		if ok {
-			val := item.value
-			//suggested at insert front
-			if c.searchCmpFunc(value, val) <= 0 {
-				c.orderedKeys = append([]interface{}{key}, c.orderedKeys...)
-				log.WithField("key ",key).WithField("ordered ",len(c.orderedKeys)).Trace("insert to front")
-				return
-			}
-		}
-	} else {
-		//todo
-	}
-	i := c.search(value)
-	c.orderedKeys = SliceInsert(c.orderedKeys, i, key)
-	log.WithFields(log.Fields{"key":key,"values":value," len ordered keys ": len(c.orderedKeys) , "suggestedAt":suggestedAt}).Trace("before insert key")
-	return
-}
-
 func (c *SimpleOrderedCache) addFrontBatch(keys []interface{}, values []interface{}) error {
 	if len(values) != len(keys) {
 		panic("len(keys) != len(values)")
@@ -366,11 +306,22 @@ func (c *SimpleOrderedCache) addFrontBatch(keys []interface{}, values []interfac
 			}
 		}
 
+		w, err := c.weigh(key, value)
+		if err != nil {
+			return err
+		}
+		if _, ok := c.items[key]; !ok || c.replaces() {
+			c.makeRoomFor(key, w)
+		}
+
 		// Check for existing item
 		item, ok := c.items[key]
 		if ok {
-			if c.searchCmpFunc == nil {
+			if c.replaces() {
 				item.value = value
+				c.weight += w - item.weight
+				item.weight = w
+				c.index.update(key, value)
 			} else {
 				//don't set again ,if using ordered insert
 			}
@@ -386,30 +337,30 @@ func (c *SimpleOrderedCache) addFrontBatch(keys []interface{}, values []interfac
 				}
 			}
 			item = &simpleItem{
-				clock: c.clock,
-				value: value,
+				clock:  c.clock,
+				value:  value,
+				weight: w,
 			}
+			c.weight += w
 			c.items[key] = item
 			insertKeys = append(insertKeys, key)
-			if c.searchCmpFunc != nil {
-				insertValues = append(insertValues, value)
-			}
+			insertValues = append(insertValues, value)
 
 		}
 
 		if c.expiration != nil {
 			t := c.clock.Now().Add(*c.expiration)
 			item.expiration = &t
+			c.expiries.set(key, &t)
 		}
 
 		if c.addedFunc != nil {
 			c.addedFunc(key, value)
 		}
 	}
-	if c.searchCmpFunc != nil {
-		c.insertKeys(insertKeys, insertValues, 0)
-	} else {
-		c.orderedKeys = append(insertKeys, c.orderedKeys...)
+	// keep the order of the batch in front of the queue
+	for i := len(insertKeys) - 1; i >= 0; i-- {
+		c.index.pushFront(insertKeys[i], insertValues[i])
 	}
 	return nil
 }
@@ -418,9 +369,15 @@ func (c *SimpleOrderedCache) addFrontBatch(keys []interface{}, values []interfac
 // If it dose not exists key and has LoaderFunc,
 // generate a value using `LoaderFunc` method returns value.
 func (c *SimpleOrderedCache) Get(key interface{}) (interface{}, error) {
+	return c.GetCtx(context.Background(), key)
+}
+
+// GetCtx works like Get, but stops waiting for the loader once ctx is done.
+// The load itself keeps running for other callers of the same key.
+func (c *SimpleOrderedCache) GetCtx(ctx context.Context, key interface{}) (interface{}, error) {
 	v, err := c.get(key, false)
 	if err == KeyNotFoundError {
-		return c.getWithLoader(key, true)
+		return c.getWithLoader(ctx, key, true)
 	}
 	return v, err
 }
@@ -431,7 +388,7 @@ func (c *SimpleOrderedCache) Get(key interface{}) (interface{}, error) {
 func (c *SimpleOrderedCache) GetIFPresent(key interface{}) (interface{}, error) {
 	v, err := c.get(key, false)
 	if err == KeyNotFoundError {
-		return c.getWithLoader(key, false)
+		return c.getWithLoader(context.Background(), key, false)
 	}
 	return v, nil
 }
@@ -459,8 +416,7 @@ func (c *SimpleOrderedCache) getValue(key interface{}, onLoad bool) (interface{}
 			}
 			return v, nil
 		}
-		//todo remove ordered key
-		c.delete(key)
+		c.delete(key, causeExpired)
 	}
 	c.mu.Unlock()
 	if !onLoad {
@@ -469,11 +425,11 @@ func (c *SimpleOrderedCache) getValue(key interface{}, onLoad bool) (interface{}
 	return nil, KeyNotFoundError
 }
 
-func (c *SimpleOrderedCache) getWithLoader(key interface{}, isWait bool) (interface{}, error) {
-	if c.loaderExpireFunc == nil {
+func (c *SimpleOrderedCache) getWithLoader(ctx context.Context, key interface{}, isWait bool) (interface{}, error) {
+	if c.loaderFunc == nil {
 		return nil, KeyNotFoundError
 	}
-	value, _, err := c.load(key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
+	value, _, err := c.load(ctx, key, func(v interface{}, expiration *time.Duration, e error) (interface{}, error) {
 		if e != nil {
 			return nil, e
 		}
@@ -483,9 +439,11 @@ func (c *SimpleOrderedCache) getWithLoader(key interface{}, isWait bool) (interf
 		if err != nil {
 			return nil, err
 		}
+		c.wakeWaiters()
 		if expiration != nil {
 			t := c.clock.Now().Add(*expiration)
 			item.(*simpleItem).expiration = &t
+			c.expiries.set(key, &t)
 		}
 		return v, nil
 	}, isWait)
@@ -495,200 +453,65 @@ func (c *SimpleOrderedCache) getWithLoader(key interface{}, isWait bool) (interf
 	return value, nil
 }
 
+// evict removes count items. Expired items go first, then the ones expireFunction flags
+// among the first keys of the queue, then the keys at the front of the queue.
 func (c *SimpleOrderedCache) evict(count int) {
 	now := c.clock.Now()
-	current := 0
-	var fail int
-	var removedKeys []int
-	for i := 0; i < len(c.orderedKeys); i++ {
-		if current >= count || fail > 3*count {
+	for ; count > 0; count-- {
+		key, ok := c.expiries.popExpired(now)
+		if !ok {
 			break
 		}
-		key := c.orderedKeys[i]
-		item, ok := c.items[key]
-		if ok {
-			if item.expiration == nil || now.After(*item.expiration) ||
-				(c.expireFunction != nil && c.expireFunction(key)) {
-				defer c.deleteVal(key)
-				removedKeys = append(removedKeys, i)
-				current++
+		c.deleteVal(key, causeExpired)
+	}
+	if count > 0 && c.expireFunction != nil {
+		// expireFunction is not indexed, give up after 3*count keys it does not flag
+		var expired []interface{}
+		fail := 0
+		c.index.ascend(func(key interface{}) bool {
+			if c.expireFunction(key) {
+				expired = append(expired, key)
 			} else {
 				fail++
 			}
-		} else {
-			removedKeys = append(removedKeys, i)
-			current++
-		}
-	}
-	c.removeKeysByIndex(removedKeys)
-}
-
-//insert a small slice into a big slice with order
-func (c *SimpleOrderedCache) insertKeysByIndex(indexes []int, keys []interface{}) {
-	if len(indexes) == 0 {
-		return
-	}
-	if len(indexes) != len(keys) {
-		panic("length mismatch")
-	}
-	if len(c.orderedKeys) == 0 {
-		c.orderedKeys = keys
-		return
-	}
-	var result []interface{}
-	first := 0
-	for i, second := range indexes {
-		if first > second {
-			panic(fmt.Sprintf("must be sorted %d %d %v", first, second, indexes))
-		}
-		if second >= len(c.orderedKeys) {
-			result = append(result, c.orderedKeys[first:]...)
-			c.orderedKeys = append(result, keys[i:]...)
-			return
-		}
-		if first == second {
-			result = append(result, keys[i])
-		} else {
-			result = append(result, c.orderedKeys[first:second]...)
-			result = append(result, keys[i])
-		}
-		first = second
-	}
-	result = append(result, c.orderedKeys[first:]...)
-	c.orderedKeys = result
-	return
-}
-
-//removeKeysByIndex
-//[][][][][remove][remove][]
-func (c *SimpleOrderedCache) removeKeysByIndex(indexes []int) {
-	if len(indexes) == 0 {
-		return
-	}
-	if len(indexes) > len(c.orderedKeys) {
-		panic("all elements will be removed")
-	}
-	oldKeys := c.orderedKeys
-	start := indexes[0]
-	end := indexes[len(indexes)-1]
-	c.orderedKeys = oldKeys[:start]
-	first := start
-	for i, second := range indexes {
-		//just process [][][][][remove][][remove][]
-		if i == 0 {
-			continue
-		}
-		if first >= second {
-			panic("must be sorted")
+			return len(expired) < count && fail <= 3*count
+		})
+		for _, key := range expired {
+			c.deleteVal(key, causeExpired)
+			count--
 		}
-		//don't append in this case  [][][][][j][k][]
-		if first+1 < second {
-			c.orderedKeys = append(c.orderedKeys, oldKeys[first+1:second]...)
-		}
-		first = second
 	}
-	c.orderedKeys = append(c.orderedKeys, oldKeys[end+1:]...)
-	log.WithFields(log.Fields{"indexes":indexes,"ordered keys ": len(c.orderedKeys)}).Trace("after remove indexes")
-}
-
-func (c*SimpleOrderedCache)search(value interface{}) int {
-	f := func(i int) bool {
-		k := c.orderedKeys[i]
-		item, ok1 := c.items[k]
-		if ok1 {
-			if c.searchCmpFunc(item.value, value) >= 0 {
-				return true
-			}
-			return false
+	for ; count > 0; count-- {
+		key, ok := c.index.front()
+		if !ok {
+			break
 		}
-		return false
+		c.deleteVal(key, causeEvicted)
 	}
-	return   Search(len(c.orderedKeys), f)
 }
 
-
-func (c*SimpleOrderedCache)searchFrom (to ,from int , value interface{}) int {
-	f := func(i int) bool {
-		k := c.orderedKeys[i]
-		item, ok1 := c.items[k]
-		if ok1 {
-			if c.searchCmpFunc(item.value, value) >= 0 {
-				return true
-			}
-			return false
-		}
-		return false
-	}
-	return  SearchFrom(to,from, f)
-}
 // Removes the provided key from the cache.
 func (c *SimpleOrderedCache) Remove(key interface{}) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.delete(key)
+	c.forgetError(key)
+	return c.delete(key, causeRemoved)
 }
 
 
-func (c *SimpleOrderedCache)delete(key interface{}) bool {
+func (c *SimpleOrderedCache)delete(key interface{}, cause removalCause) bool {
 	log.Tracef("item will be deleted %v",key)
-	item, ok  := c.items[key]
-	if ok {
-		if c.searchCmpFunc != nil {
-			var found bool
-			i:=c.search(item.value)
-			j := i
-			for ; j < len(c.orderedKeys); j++ {
-				if c.orderedKeys[j] == key {
-						log.Debugf("found key at index i %d , the key[i] %v ,j %d,,key [j] %v  ", i, c.orderedKeys[i], j, c.orderedKeys[j])
-					c.orderedKeys = append(c.orderedKeys[:j], c.orderedKeys[j+1:]...)
-					found = true
-					break
-				}
-				itemJ, ok := c.items[c.orderedKeys[j]]
-				if ok {
-					if c.searchCmpFunc(itemJ.value, item.value) > 0 {
-						break
-					}
-				}
-			}
-			if !found  {
-				if i >= len(c.orderedKeys) {
-					log.Debugf("not found key i %d , j %d",i,j)
-				} else if j >= len(c.orderedKeys){
-					log.Debugf("not found key i %d, j %d, key[i] %v",i,j ,c.orderedKeys[i])
-				} else{
-					log.Debugf(" not found key at index i %d , the key[i] %v ,j %d,,key [j] %v  ", i, c.orderedKeys[i], j, c.orderedKeys[j])
-				}
-			}
-
-		} else {
-			j := 0
-			for i, k := range c.orderedKeys {
-				if k == key {
-					c.orderedKeys = append(c.orderedKeys[:i], c.orderedKeys[i+1:]...)
-					break
-				}
-				j= i
-			}
-			log.Debugf("cmp times %d ", j)
-		}
-	}
-	ok = c.deleteVal(key)
-	if len(c.items) == 0 {
-		c.orderedKeys = nil
-	} else if len(c.items) == 1 {
-		c.orderedKeys = nil
-		for k := range c.items {
-			c.orderedKeys = append(c.orderedKeys, k)
-		}
-	}
-	return  ok
+	return c.deleteVal(key, cause)
 }
 
-func (c *SimpleOrderedCache) deleteVal(key interface{}) bool {
+func (c *SimpleOrderedCache) deleteVal(key interface{}, cause removalCause) bool {
 	item, ok := c.items[key]
 	if ok {
 		delete(c.items, key)
+		c.index.remove(key)
+		c.expiries.remove(key)
+		c.weight -= item.weight
+		c.stats.incrRemoval(cause)
 		if c.evictedFunc != nil {
 			c.evictedFunc(key, item.value)
 		}
@@ -698,24 +521,14 @@ func (c *SimpleOrderedCache) deleteVal(key interface{}) bool {
 }
 
 // Returns a slice of the keys in the cache.
-func (c *SimpleOrderedCache) keys() []interface{} {
+// Like GetALL it does not count as a lookup in the stats.
+func (c *SimpleOrderedCache) Keys() []interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	keys := make([]interface{}, len(c.items))
-	var i = 0
-	for k := range c.items {
-		keys[i] = k
-		i++
-	}
-	return keys
-}
-
-// Returns a slice of the keys in the cache.
-func (c *SimpleOrderedCache) Keys() []interface{} {
-	keys := []interface{}{}
-	for _, k := range c.keys() {
-		_, err := c.GetIFPresent(k)
-		if err == nil {
+	now := c.clock.Now()
+	keys := make([]interface{}, 0, len(c.items))
+	for k, item := range c.items {
+		if !item.IsExpired(&now) {
 			keys = append(keys, k)
 		}
 	}
@@ -724,11 +537,22 @@ func (c *SimpleOrderedCache) Keys() []interface{} {
 
 // Returns all key-value pairs in the cache.
 func (c *SimpleOrderedCache) GetALL() map[interface{}]interface{} {
-	m := make(map[interface{}]interface{})
-	for _, k := range c.keys() {
-		v, err := c.GetIFPresent(k)
-		if err == nil {
-			m[k] = v
+	c.mu.RLock()
+	now := c.clock.Now()
+	m := make(map[interface{}]interface{}, len(c.items))
+	for k, item := range c.items {
+		if !item.IsExpired(&now) {
+			m[k] = item.value
+		}
+	}
+	c.mu.RUnlock()
+	if c.deserializeFunc != nil {
+		for k, v := range m {
+			if v, err := c.deserializeFunc(k, v); err == nil {
+				m[k] = v
+			} else {
+				delete(m, k)
+			}
 		}
 	}
 	return m
@@ -742,26 +566,19 @@ func (c *SimpleOrderedCache) Refresh() {
 	c.getALl()
 }
 
-func (c *SimpleOrderedCache) len() (keyLen, itemLen int) {
+// Returns the number of items in the cache, expired items are removed first.
+func (c *SimpleOrderedCache) Len() int {
 	c.mu.Lock()
-	keyLen = len(c.orderedKeys)
-	itemLen = len(c.items)
-	c.mu.Unlock()
-	if itemLen > keyLen {
-		panic(fmt.Sprintf("item len must be smaller itemlen %d, keyLen %d", itemLen, keyLen))
-	}
-	return keyLen, itemLen
+	defer c.mu.Unlock()
+	c.dropExpired()
+	return c.index.len()
 }
 
-// Returns the number of items in the cache.
-func (c *SimpleOrderedCache) Len() int {
-	//return len(c.GetALL())
-	keyLen, itemLen := c.len()
-	if itemLen < keyLen/2 {
-		c.getALl()
-	}
-	keyLen, itemLen = c.len()
-	return keyLen
+// QueueLen returns the number of queued keys, unlike Len it counts expired items until they are removed.
+func (c *SimpleOrderedCache) QueueLen() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index.len()
 }
 
 // Completely clear the cache
@@ -779,38 +596,19 @@ func (c *SimpleOrderedCache) Purge() {
 }
 
 func (c *SimpleOrderedCache) OrderedKeys() []interface{} {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.orderedKeys
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return indexKeys(c.index)
 }
 
-func (c *SimpleOrderedCache) moveFront(key interface{}) (err error) {
-	var try = 2
+func (c *SimpleOrderedCache) moveFront(key interface{}) error {
 	c.mu.Lock()
-	_, ok := c.items[key]
-	if !ok {
-		defer c.stats.IncrMissCount()
-	}
 	defer c.mu.Unlock()
-	if ok {
-		if len(c.orderedKeys) == 0 {
-			return EmptyErr
-		}
-		//if key is first two item ,don't move it
-		for i := 0; i < try && i < len(c.orderedKeys); i++ {
-			head := c.orderedKeys[i]
-			if head == key {
-				return nil
-			}
-		}
-		if len(c.orderedKeys) >= c.size {
-			err = ReachedMaxSizeErr
-		}
-		arr := []interface{}{key}
-		c.orderedKeys = append(arr, c.orderedKeys...)
-		return err
+	if !c.index.(*listIndex).moveFront(key) {
+		c.stats.IncrMissCount()
+		return KeyNotFoundError
 	}
-	return KeyNotFoundError
+	return nil
 }
 
 func (c *SimpleOrderedCache) MoveFront(key interface{}) error {
@@ -823,127 +621,99 @@ func (c *SimpleOrderedCache) MoveFront(key interface{}) error {
 func (c *SimpleOrderedCache) getALl() (keys []interface{}, values []interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	var index []int
-	for i, key := range c.orderedKeys {
-		item, ok := c.items[key]
-		if ok {
-			if !item.IsExpired(nil) {
-				v := item.value
-				if c.deserializeFunc != nil {
-					v, _ = c.deserializeFunc(key, v)
-				}
-				values = append(values, v)
-				keys = append(keys, key)
-				//c.stats.IncrHitCount()
-			} else {
-				index = append(index, i)
-				log.Debugf("expired value will be removed %v %v",key ,item.value)
-				c.deleteVal(key)
-			}
-		} else {
-			//c.stats.IncrMissCount()
-			index = append(index, i)
+	var expired []interface{}
+	c.index.ascend(func(key interface{}) bool {
+		item := c.items[key]
+		if item.IsExpired(nil) {
+			expired = append(expired, key)
+			return true
 		}
+		v := item.value
+		if c.deserializeFunc != nil {
+			v, _ = c.deserializeFunc(key, v)
+		}
+		values = append(values, v)
+		keys = append(keys, key)
+		return true
+	})
+	for _, key := range expired {
+		log.Debugf("expired value will be removed %v",key)
+		c.deleteVal(key, causeExpired)
 	}
-	c.removeKeysByIndex(index)
 	return keys, values
 }
 
-func (c *SimpleOrderedCache) getTop() (key interface{}, value interface{}, err error) {
-	c.mu.Lock()
-	var removedIndex []int
-	for i, k := range c.orderedKeys {
-		//key =  c.keyTypeFunction(k)
-		key = k
-		item, ok := c.items[key]
-		if ok {
-			value = item.value
-			if item.IsExpired(nil) {
-				removedIndex = append(removedIndex, i)
-				log.Debugf("expired value will be removed %v %v",key ,item.value)
-				c.deleteVal(key)
-			}
+// first returns the first live item of the queue, remove takes it out of the queue.
+// Expired items in front of it are removed, it is called with the lock held.
+func (c *SimpleOrderedCache) first(remove bool) (key interface{}, value interface{}, found bool) {
+	for !found {
+		k, ok := c.index.front()
+		if !ok {
 			break
 		}
-		removedIndex = append(removedIndex, i)
-		log.Debugf("expired value will be removed %v ",key)
-		c.deleteVal(key)
+		item := c.items[k]
+		if item.IsExpired(nil) {
+			log.Debugf("expired value will be removed %v %v",k ,item.value)
+			c.deleteVal(k, causeExpired)
+			continue
+		}
+		key, value, found = k, item.value, true
+		if remove {
+			c.deleteVal(k, causeDequeued)
+		}
 	}
-	c.removeKeysByIndex(removedIndex)
+	return key, value, found
+}
+
+// take removes up to count live items from the front of the queue, it is called with the lock held.
+func (c *SimpleOrderedCache) take(count int) (keys []interface{}, values []interface{}) {
+	for len(keys) < count {
+		k, v, ok := c.first(true)
+		if !ok {
+			break
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	return keys, values
+}
+
+// top returns the first live item of the queue, remove takes it out of the queue.
+func (c *SimpleOrderedCache) top(remove bool) (key interface{}, value interface{}, err error) {
+	c.mu.Lock()
+	key, value, found := c.first(remove)
 	c.mu.Unlock()
-	if value != nil {
-		c.stats.IncrHitCount()
-		return key, value, nil
-	} else {
+	if !found {
 		c.stats.IncrMissCount()
 		return nil, nil, EmptyErr
 	}
+	c.stats.IncrHitCount()
+	return key, value, nil
+}
+
+func (c *SimpleOrderedCache) getTop() (key interface{}, value interface{}, err error) {
+	return c.top(false)
 }
 
 func (c *SimpleOrderedCache) GetTop() (key interface{}, value interface{}, err error) {
 	return c.getTop()
 }
 
+// deQueueBatch takes up to count live items from the front of the queue.
 func (c *SimpleOrderedCache) deQueueBatch(count int) (keys []interface{}, values []interface{}, err error) {
 	c.mu.Lock()
-	var removedIndex []int
-	current := 0
-	all := false
-	if count >= len(c.orderedKeys) {
-		all = true
-	}
-	for i, key := range c.orderedKeys {
-		item, ok := c.items[key]
-		if ok {
-			if current >= count {
-				break
-			}
-			value := item.value
-			values = append(values, value)
-			keys = append(keys, key)
-			current++
-		}
-		removedIndex = append(removedIndex, i)
-		c.deleteVal(key)
-	}
-	if all {
-		log.Debugf("init cache , removed all")
-		c.init()
-	} else {
-		c.removeKeysByIndex(removedIndex)
-	}
+	keys, values = c.take(count)
 	c.mu.Unlock()
-	if len(values) != 0 {
-		c.stats.IncrHitCount()
-		return keys, values, nil
-	} else {
+	if len(keys) == 0 {
 		c.stats.IncrMissCount()
 		return nil, nil, EmptyErr
 	}
+	c.stats.IncrHitCount()
+	return keys, values, nil
 }
 
 func (c *SimpleOrderedCache) deQueue() (key interface{}, value interface{}, err error) {
-	c.mu.Lock()
-	var removedIndex []int
-	for i, key := range c.orderedKeys {
-		removedIndex = append(removedIndex, i)
-		item, ok := c.items[key]
-		if ok {
-			value = item.value
-			c.deleteVal(key)
-			break
-		}
-		c.deleteVal(key)
-	}
-	c.removeKeysByIndex(removedIndex)
-	c.mu.Unlock()
-	if value != nil {
-		c.stats.IncrHitCount()
-		return key, value, nil
-	} else {
-		c.stats.IncrMissCount()
-		return key, nil, EmptyErr
-	}
+	return c.top(true)
 }
 
 func (c *SimpleOrderedCache) DeQueue() (key interface{}, value interface{}, err error) {
@@ -958,19 +728,21 @@ func (c *SimpleOrderedCache) EnQueue(key interface{}, value interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	_, err := c.enQueue(key, value)
+	c.wakeWaiters()
 	return err
 }
 
-//EnQueueBatch if searchCmpFunc is not nil , keys are  required sorted
 func (c *SimpleOrderedCache) EnQueueBatch(keys []interface{}, values []interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.wakeWaiters()
 	return c.enQueueBatch(keys, values)
 }
 
 func (c *SimpleOrderedCache) PrependBatch(keys []interface{}, values []interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.wakeWaiters()
 	return c.addFrontBatch(keys, values)
 }
 
@@ -978,6 +750,7 @@ func (c *SimpleOrderedCache) Prepend(key interface{}, value interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	_, err := c.addFront(key, value)
+	c.wakeWaiters()
 	return err
 }
 
@@ -987,60 +760,139 @@ func (c *SimpleOrderedCache) RemoveExpired(allowFailCount int) error {
 	return c.removeExpired(allowFailCount)
 }
 
+// Sort reorders the queue with the SortKeysFunc, keys it leaves out stay behind in their old order.
 func (c *SimpleOrderedCache) Sort() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.searchCmpFunc != nil {
+		// the index is ordered by the SearchCompareFunction already
+		return
+	}
+	var values []interface{}
+	for key, item := range c.items {
+		if item.IsExpired(nil) {
+			log.Debugf("remove expired key %v, value %v",key,item.value)
+			c.delete(key, causeExpired)
+			continue
+		}
+		values = append(values, item.value)
+	}
 	getItem := func(key interface{}) (value interface{}, ok bool) {
 		item, ok := c.items[key]
 		if ok {
 			value = item.value
-			if item.IsExpired(nil) {
-				log.Debug("remove expired key %v, value %v",key,value)
-				c.delete(key)
-			}
 		}
 		return value, ok
 	}
-	var values []interface{}
-	for key, item := range c.items {
-		if item.IsExpired(nil) {
-			c.delete(key)
+	old := indexKeys(c.index)
+	keys, _ := c.sortKeysFunc(old, values, getItem)
+	c.index.reset()
+	seen := make(map[interface{}]struct{}, len(old))
+	for _, key := range append(keys, old...) {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if item, ok := c.items[key]; ok {
+			seen[key] = struct{}{}
+			c.index.push(key, item.value)
 		}
-		values = append(values, item.value)
 	}
-	keys, _ := c.sortKeysFunc(c.orderedKeys, values, getItem)
-	c.orderedKeys = keys
+}
+
+// dropExpired removes the items whose expiration has passed, it is called with the lock held.
+func (c *SimpleOrderedCache) dropExpired() {
+	now := c.clock.Now()
+	for key, ok := c.expiries.popExpired(now); ok; key, ok = c.expiries.popExpired(now) {
+		c.deleteVal(key, causeExpired)
+	}
 }
 
 func (c *SimpleOrderedCache) removeExpired(allowFailCount int) error {
+	c.dropExpired()
+	if c.expireFunction == nil {
+		return nil
+	}
+	// expireFunction is not indexed, check the keys from the front of the queue
 	var try int
-	var removedIndex []int
-	for i, k := range c.orderedKeys {
-		//key :=  c.keyTypeFunction(k)
-		key := k
-		if try > allowFailCount {
+	var expired []interface{}
+	c.index.ascend(func(key interface{}) bool {
+		if c.expireFunction(key) {
+			expired = append(expired, key)
+		} else {
+			try++
+		}
+		return try <= allowFailCount
+	})
+	for _, key := range expired {
+		c.deleteVal(key, causeExpired)
+	}
+	return nil
+}
+
+// deleteExpired removes every expired item, it is run by the janitor.
+func (c *SimpleOrderedCache) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeExpired(len(c.items))
+}
+
+// Save writes a snapshot of the cache to w, keeping the order of the keys.
+func (c *SimpleOrderedCache) Save(w io.Writer) error {
+	c.mu.RLock()
+	sw := newSnapshotWriter(&c.baseCache, snapshotKindOrdered)
+	entries := make([]snapshotEntry, 0, len(c.items))
+	c.index.ascend(func(key interface{}) bool {
+		item := c.items[key]
+		entries = append(entries, snapshotEntry{key: key, value: item.value, expiration: item.expiration})
+		return true
+	})
+	c.mu.RUnlock()
+
+	if err := sw.writeEntries(entries); err != nil {
+		return err
+	}
+	return sw.flush(w)
+}
+
+// Load replaces the contents of the cache with a snapshot written by Save.
+func (c *SimpleOrderedCache) Load(r io.Reader) error {
+	sr, err := readSnapshot(&c.baseCache, r, snapshotKindOrdered)
+	if err != nil {
+		return err
+	}
+	entries, err := sr.readEntries()
+	if err != nil {
+		return err
+	}
+	if err := sr.done(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	for _, e := range entries {
+		if c.size > 0 && len(c.items) >= c.size {
 			break
 		}
-		item, ok := c.items[key]
-		if ok {
-			if item.IsExpired(nil) {
-				removedIndex = append(removedIndex, i)
-				c.deleteVal(key)
-				continue
-			} else if c.expireFunction != nil {
-				if c.expireFunction(key) {
-					removedIndex = append(removedIndex, i)
-					c.deleteVal(key)
-					continue
-				}
-			}
-			try++
-		}else {
-			removedIndex = append(removedIndex, i)
-			c.deleteVal(key)
+		if _, ok := c.items[e.key]; ok {
+			continue
+		}
+		w, err := c.weigh(e.key, e.value)
+		if err != nil || c.overweight(w) {
+			continue
+		}
+		c.weight += w
+		c.items[e.key] = &simpleItem{
+			clock:      c.clock,
+			value:      e.value,
+			expiration: e.expiration,
+			weight:     w,
 		}
+		c.expiries.set(e.key, e.expiration)
+		c.index.push(e.key, e.value)
 	}
-	c.removeKeysByIndex(removedIndex)
+	c.wakeWaiters()
 	return nil
 }
 
@@ -1060,3 +912,23 @@ func (c *SimpleOrderedCache) PrintValues(stamp int) {
 		}
 	}
 }
+
+type simpleItem struct {
+	clock      Clock
+	value      interface{}
+	expiration *time.Time
+	moved      bool
+	weight     int64
+}
+
+// returns boolean value whether this item is expired or not.
+func (si *simpleItem) IsExpired(now *time.Time) bool {
+	if si.expiration == nil {
+		return false
+	}
+	if now == nil {
+		t := si.clock.Now()
+		now = &t
+	}
+	return si.expiration.Before(*now)
+}
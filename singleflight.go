@@ -19,13 +19,22 @@ limitations under the License.
 // This module provides a duplicate function call suppression
 // mechanism.
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // call is an in-flight or completed Do call
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{}
+	val  interface{}
+	err  error
+
+	// ctx is handed to fn, it is cancelled once nobody waits for the result anymore.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int  // protected by Group.mu
+	async   bool // started by a non waiting caller, never cancelled
 }
 
 // Group represents a class of work and forms a namespace in which
@@ -43,15 +52,17 @@ type Group struct {
 // time. If a duplicate comes in, the duplicate caller waits for the
 // original to complete and receives the same results.
 func (g *Group) Do(key interface{}, fn func() (interface{}, error), isWait bool) (interface{}, bool, error) {
-	g.mu.Lock()
-	var v interface{}
-	var err error
-	if g.cache == nil {
-		v, err = g.orderedCache.get(key, true)
-	} else {
-		v, err = g.cache.get(key, true)
-	}
+	return g.DoCtx(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	}, isWait)
+}
 
+// DoCtx is like Do, but a waiting caller returns ctx.Err() as soon as ctx is done.
+// The execution itself keeps going for the other callers of the same key and
+// its context is only cancelled when every waiting caller has given up.
+func (g *Group) DoCtx(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error), isWait bool) (interface{}, bool, error) {
+	g.mu.Lock()
+	v, err := g.cached(key)
 	if err == nil {
 		g.mu.Unlock()
 		return v, false, nil
@@ -60,32 +71,127 @@ func (g *Group) Do(key interface{}, fn func() (interface{}, error), isWait bool)
 		g.m = make(map[interface{}]*call)
 	}
 	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
 		if !isWait {
+			g.mu.Unlock()
 			return nil, false, KeyNotFoundError
 		}
-		c.wg.Wait()
-		return c.val, false, c.err
+		c.waiters++
+		g.mu.Unlock()
+		return g.wait(ctx, key, c, false)
 	}
-	c := new(call)
-	c.wg.Add(1)
+	c := &call{done: make(chan struct{})}
+	c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
 	g.m[key] = c
-	g.mu.Unlock()
 	if !isWait {
+		c.async = true
+		g.mu.Unlock()
 		go g.call(c, key, fn)
 		return nil, false, KeyNotFoundError
 	}
-	v, err = g.call(c, key, fn)
-	return v, true, err
+	c.waiters++
+	g.mu.Unlock()
+	if ctx.Done() == nil {
+		// ctx can never be cancelled, so there is no need to leave this goroutine.
+		v, err = g.call(c, key, fn)
+		return v, true, err
+	}
+	go g.call(c, key, fn)
+	return g.wait(ctx, key, c, true)
 }
 
-func (g *Group) call(c *call, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
-	c.val, c.err = fn()
-	c.wg.Done()
+// cached looks key up in the cache without counting the lookup.
+func (g *Group) cached(key interface{}) (interface{}, error) {
+	if g.cache == nil {
+		return g.orderedCache.get(key, true)
+	}
+	return g.cache.get(key, true)
+}
 
+// claim starts a bulk load of keys. Stored keys are returned in vals, keys with a call
+// in flight get a waiter in waiting, to be passed to wait, and a call is registered for
+// every other key. The caller loads those keys itself and has to finish their calls with complete.
+func (g *Group) claim(keys []interface{}) (vals map[interface{}]interface{}, calls, waiting map[interface{}]*call) {
+	vals = make(map[interface{}]interface{})
+	calls = make(map[interface{}]*call)
+	waiting = make(map[interface{}]*call)
 	g.mu.Lock()
-	delete(g.m, key)
+	defer g.mu.Unlock()
+	if g.m == nil {
+		g.m = make(map[interface{}]*call)
+	}
+	for _, key := range keys {
+		if _, ok := calls[key]; ok {
+			continue
+		}
+		if _, ok := waiting[key]; ok {
+			continue
+		}
+		if v, err := g.cached(key); err == nil {
+			vals[key] = v
+			continue
+		}
+		if c, ok := g.m[key]; ok {
+			c.waiters++
+			waiting[key] = c
+			continue
+		}
+		// the caller runs the load, nobody can cancel it
+		c := &call{done: make(chan struct{}), async: true}
+		g.m[key] = c
+		calls[key] = c
+	}
+	return vals, calls, waiting
+}
+
+// complete finishes the calls registered by claim. A key gets its value from vals,
+// or err, or KeyNotFoundError if it has neither.
+func (g *Group) complete(calls map[interface{}]*call, vals map[interface{}]interface{}, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, c := range calls {
+		if v, ok := vals[key]; ok {
+			c.val = v
+		} else if err != nil {
+			c.err = err
+		} else {
+			c.err = KeyNotFoundError
+		}
+		close(c.done)
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+	}
+}
+
+func (g *Group) wait(ctx context.Context, key interface{}, c *call, called bool) (interface{}, bool, error) {
+	select {
+	case <-c.done:
+		return c.val, called, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 && !c.async {
+			// forget the abandoned call, so a later caller starts a fresh one.
+			if g.m[key] == c {
+				delete(g.m, key)
+			}
+			c.cancel()
+		}
+		g.mu.Unlock()
+		return nil, false, ctx.Err()
+	}
+}
+
+func (g *Group) call(c *call, key interface{}, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	c.val, c.err = fn(c.ctx)
+	close(c.done)
+
+	g.mu.Lock()
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
 	g.mu.Unlock()
+	c.cancel()
 
 	return c.val, c.err
 }
@@ -17,6 +17,7 @@ limitations under the License.
 */
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -86,3 +87,68 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestDoCtxWaiterCancel(t *testing.T) {
+	var g Group
+	g.cache = New(32).Build()
+	release := make(chan struct{})
+	var calls int32
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", ctx.Err()
+	}
+
+	result := make(chan interface{})
+	go func() {
+		v, _, err := g.DoCtx(context.Background(), "key", fn, true)
+		if err != nil {
+			t.Errorf("Do error: %v", err)
+		}
+		result <- v
+	}()
+	time.Sleep(50 * time.Millisecond) // let the first call start
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := g.DoCtx(ctx, "key", fn, true)
+	if err != context.DeadlineExceeded {
+		t.Errorf("err should be %v, not %v", context.DeadlineExceeded, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancelled waiter should return right away")
+	}
+
+	close(release)
+	if v := <-result; v != "bar" {
+		t.Errorf("got %q; want %q", v, "bar")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("number of calls = %d; want 1", got)
+	}
+}
+
+func TestDoCtxCancelsAbandonedCall(t *testing.T) {
+	var g Group
+	g.cache = New(32).Build()
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, _, err := g.DoCtx(ctx, "key", fn, true); err != context.Canceled {
+		t.Errorf("err should be %v, not %v", context.Canceled, err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("loader context should be cancelled once every waiter is gone")
+	}
+}
@@ -0,0 +1,142 @@
+package gcache
+
+import (
+	"container/list"
+)
+
+// SLRUCache is a segmented LRU. New items go to the probation segment and are
+// promoted to the protected segment on their second hit, so items which were
+// used only once are evicted before the ones which were used again.
+type SLRUCache struct {
+	policyCache
+}
+
+func newSLRUCache(cb *CacheBuilder) *SLRUCache {
+	c := &SLRUCache{}
+	c.setup(cb, TYPE_SLRU, &slruPolicy{protectedSize: cb.size * 8 / 10})
+	return c
+}
+
+// slruPolicy keeps the keys in the probation and protected segments,
+// the most recently used one at the front of each.
+type slruPolicy struct {
+	probation     *list.List
+	protected     *list.List
+	elements      map[interface{}]*list.Element
+	protectedSize int
+}
+
+type slruNode struct {
+	key       interface{}
+	protected bool
+}
+
+func (p *slruPolicy) segment(n *slruNode) *list.List {
+	if n.protected {
+		return p.protected
+	}
+	return p.probation
+}
+
+func (p *slruPolicy) OnAdd(key interface{}) {
+	p.elements[key] = p.probation.PushFront(&slruNode{key: key})
+}
+
+// OnAccess moves key to the front of the protected segment, the least recently
+// used protected items go back to probation if it grows too large.
+func (p *slruPolicy) OnAccess(key interface{}) {
+	e, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	n := e.Value.(*slruNode)
+	if n.protected {
+		p.protected.MoveToFront(e)
+		return
+	}
+	p.probation.Remove(e)
+	n.protected = true
+	p.elements[key] = p.protected.PushFront(n)
+	p.demote()
+}
+
+// demote moves the items which overflow the protected segment back to probation.
+func (p *slruPolicy) demote() {
+	for p.protected.Len() > p.protectedSize {
+		e := p.protected.Back()
+		p.protected.Remove(e)
+		n := e.Value.(*slruNode)
+		n.protected = false
+		p.elements[n.key] = p.probation.PushFront(n)
+	}
+}
+
+func (p *slruPolicy) OnRemove(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		p.segment(e.Value.(*slruNode)).Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim takes the least recently used probation item and only then the protected ones.
+func (p *slruPolicy) Victim() (interface{}, bool) {
+	e := p.probation.Back()
+	if e == nil {
+		e = p.protected.Back()
+	}
+	if e == nil {
+		return nil, false
+	}
+	return e.Value.(*slruNode).key, true
+}
+
+func (p *slruPolicy) Reset() {
+	p.probation = list.New()
+	p.protected = list.New()
+	p.elements = make(map[interface{}]*list.Element)
+}
+
+// save keeps the segment and recency order of the items.
+func (p *slruPolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	var segments [2][]snapshotEntry
+	for i, l := range []*list.List{p.protected, p.probation} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			segments[i] = append(segments[i], entry(e.Value.(*slruNode).key))
+		}
+	}
+	return func(sw *snapshotWriter) error {
+		for _, entries := range segments {
+			if err := sw.writeEntries(entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// load restores the segments, if the snapshot holds more items than the cache size probation items are dropped first.
+func (p *slruPolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	protected, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	probation, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	return func(add func(e snapshotEntry) bool) {
+		for _, seg := range []struct {
+			entries   []snapshotEntry
+			protected bool
+		}{{protected, true}, {probation, false}} {
+			for _, e := range seg.entries {
+				if add(e) {
+					n := &slruNode{key: e.key, protected: seg.protected}
+					p.elements[e.key] = p.segment(n).PushBack(n)
+				}
+			}
+		}
+		// the protected segment of a larger cache may not fit into this one
+		p.demote()
+	}, nil
+}
@@ -0,0 +1,41 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestSLRUHitRateWithScans(t *testing.T) {
+	trace := hotSetWithScans(100000)
+	lru := replayHitRate(New(150).LRU().Build(), trace)
+	slru := replayHitRate(New(150).SLRU().Build(), trace)
+	if slru <= lru {
+		t.Errorf("slru hit rate %.3f should be higher than lru %.3f", slru, lru)
+	}
+}
+
+func TestSLRUProtectsReusedItems(t *testing.T) {
+	gc := New(5).SLRU().Build()
+	gc.Set(0, 0)
+	gc.Get(0)
+	for i := 1; i < 10; i++ {
+		gc.Set(i, i)
+	}
+	if _, err := gc.GetIFPresent(0); err != nil {
+		t.Errorf("a protected item should survive a scan: %v", err)
+	}
+}
+
+func TestSLRUDemotion(t *testing.T) {
+	gc := New(5).SLRU().Build().(*SLRUCache)
+	for i := 0; i < 5; i++ {
+		gc.Set(i, i)
+		gc.Get(i)
+	}
+	p := gc.policy.(*slruPolicy)
+	if n := p.protected.Len(); n != 4 {
+		t.Errorf("%v != %v", n, 4)
+	}
+	if n := p.elements[0].Value.(*slruNode); n.protected {
+		t.Error("the least recently used protected item should be demoted")
+	}
+}
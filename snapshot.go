@@ -0,0 +1,291 @@
+package gcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Snapshot layout, all integers are varints unless noted:
+//
+//	magic "GCSN" | version byte | cache kind | body | crc32 (4 bytes, big endian)
+//
+// The crc covers everything before it. An entry is written as
+// key | value | remaining ttl in nanoseconds (-1: never expires) | frequency.
+const (
+	snapshotMagic   = "GCSN"
+	snapshotVersion = 1
+
+	snapshotKindOrdered = "ordered"
+)
+
+var InvalidSnapshotErr = errors.New("invalid snapshot")
+var SnapshotChecksumErr = errors.New("snapshot checksum mismatch")
+
+// Codec converts keys or values to bytes when a cache is saved and back when it is loaded.
+type Codec interface {
+	Encode(interface{}) ([]byte, error)
+	Decode([]byte) (interface{}, error)
+}
+
+// GobCodec is the default Codec. Types other than the gob basic types
+// have to be registered with gob.Register before they can be saved.
+type GobCodec struct{}
+
+func (GobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+type snapshotEntry struct {
+	key        interface{}
+	value      interface{}
+	expiration *time.Time
+	freq       uint64
+}
+
+type snapshotWriter struct {
+	buf        bytes.Buffer
+	keyCodec   Codec
+	valueCodec Codec
+	now        time.Time
+}
+
+// newSnapshotWriter writes the snapshot header, c may be nil for a snapshot without entries of its own.
+func newSnapshotWriter(c *baseCache, kind string) *snapshotWriter {
+	w := &snapshotWriter{}
+	if c != nil {
+		w.keyCodec, w.valueCodec, w.now = c.keyCodec, c.valueCodec, c.clock.Now()
+	}
+	w.buf.WriteString(snapshotMagic)
+	w.buf.WriteByte(snapshotVersion)
+	w.writeBytes([]byte(kind))
+	return w
+}
+
+func (w *snapshotWriter) writeUvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+func (w *snapshotWriter) writeVarint(x int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutVarint(b[:], x)])
+}
+
+func (w *snapshotWriter) writeBytes(b []byte) {
+	w.writeUvarint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *snapshotWriter) writeKey(key interface{}) error {
+	b, err := w.keyCodec.Encode(key)
+	if err != nil {
+		return fmt.Errorf("encode key %v: %v", key, err)
+	}
+	w.writeBytes(b)
+	return nil
+}
+
+func (w *snapshotWriter) writeKeys(keys []interface{}) error {
+	w.writeUvarint(uint64(len(keys)))
+	for _, key := range keys {
+		if err := w.writeKey(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntries writes the entries which are not expired yet.
+func (w *snapshotWriter) writeEntries(entries []snapshotEntry) error {
+	live := entries[:0:0]
+	for _, e := range entries {
+		if e.expiration == nil || e.expiration.After(w.now) {
+			live = append(live, e)
+		}
+	}
+	w.writeUvarint(uint64(len(live)))
+	for _, e := range live {
+		if err := w.writeKey(e.key); err != nil {
+			return err
+		}
+		b, err := w.valueCodec.Encode(e.value)
+		if err != nil {
+			return fmt.Errorf("encode value of key %v: %v", e.key, err)
+		}
+		w.writeBytes(b)
+		ttl := int64(-1)
+		if e.expiration != nil {
+			ttl = int64(e.expiration.Sub(w.now))
+		}
+		w.writeVarint(ttl)
+		w.writeUvarint(e.freq)
+	}
+	return nil
+}
+
+// flush appends the checksum and writes the whole snapshot to out.
+func (w *snapshotWriter) flush(out io.Writer) error {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(w.buf.Bytes()))
+	w.buf.Write(sum[:])
+	_, err := w.buf.WriteTo(out)
+	return err
+}
+
+type snapshotReader struct {
+	r          *bytes.Reader
+	keyCodec   Codec
+	valueCodec Codec
+	now        time.Time
+}
+
+// readSnapshot verifies the checksum, version and cache kind of the snapshot in r.
+// Like newSnapshotWriter, c may be nil.
+func readSnapshot(c *baseCache, in io.Reader, kind string) (*snapshotReader, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(snapshotMagic)+1+4 || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, InvalidSnapshotErr
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, SnapshotChecksumErr
+	}
+	if v := body[len(snapshotMagic)]; v != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", v)
+	}
+	sr := &snapshotReader{r: bytes.NewReader(body[len(snapshotMagic)+1:])}
+	if c != nil {
+		sr.keyCodec, sr.valueCodec, sr.now = c.keyCodec, c.valueCodec, c.clock.Now()
+	}
+	k, err := sr.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	if string(k) != kind {
+		return nil, fmt.Errorf("snapshot of a %s cache can not be loaded into a %s cache", k, kind)
+	}
+	return sr, nil
+}
+
+func (sr *snapshotReader) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(sr.r)
+	if err != nil {
+		return 0, InvalidSnapshotErr
+	}
+	return x, nil
+}
+
+func (sr *snapshotReader) readVarint() (int64, error) {
+	x, err := binary.ReadVarint(sr.r)
+	if err != nil {
+		return 0, InvalidSnapshotErr
+	}
+	return x, nil
+}
+
+func (sr *snapshotReader) readBytes() ([]byte, error) {
+	n, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(sr.r.Len()) {
+		return nil, InvalidSnapshotErr
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		return nil, InvalidSnapshotErr
+	}
+	return b, nil
+}
+
+func (sr *snapshotReader) readKey() (interface{}, error) {
+	b, err := sr.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	key, err := sr.keyCodec.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %v", err)
+	}
+	return key, nil
+}
+
+func (sr *snapshotReader) readKeys() ([]interface{}, error) {
+	n, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	var keys []interface{}
+	for i := uint64(0); i < n; i++ {
+		key, err := sr.readKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readEntries reads entries and turns their remaining ttl back into an expiration time.
+func (sr *snapshotReader) readEntries() ([]snapshotEntry, error) {
+	n, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	var entries []snapshotEntry
+	for i := uint64(0); i < n; i++ {
+		var e snapshotEntry
+		if e.key, err = sr.readKey(); err != nil {
+			return nil, err
+		}
+		b, err := sr.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		if e.value, err = sr.valueCodec.Decode(b); err != nil {
+			return nil, fmt.Errorf("decode value of key %v: %v", e.key, err)
+		}
+		ttl, err := sr.readVarint()
+		if err != nil {
+			return nil, err
+		}
+		if ttl >= 0 {
+			t := sr.now.Add(time.Duration(ttl))
+			e.expiration = &t
+		}
+		if e.freq, err = sr.readUvarint(); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// done fails if there is trailing data after the body.
+func (sr *snapshotReader) done() error {
+	if sr.r.Len() != 0 {
+		return InvalidSnapshotErr
+	}
+	return nil
+}
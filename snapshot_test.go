@@ -0,0 +1,213 @@
+package gcache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		gc := New(10).EvictType(tp).Clock(clock).Build()
+		for i := 0; i < 5; i++ {
+			gc.Set(i, fmt.Sprintf("v%d", i))
+		}
+		gc.SetWithExpire("short", "s", time.Second)
+		gc.SetWithExpire("long", "l", time.Hour)
+
+		var buf bytes.Buffer
+		if err := gc.Save(&buf); err != nil {
+			t.Fatalf("%s: %v", tp, err)
+		}
+
+		// time spent between Save and Load does not count against the ttl
+		clock.Advance(time.Hour)
+		restored := New(10).EvictType(tp).Clock(clock).Build()
+		if err := restored.Load(&buf); err != nil {
+			t.Fatalf("%s: %v", tp, err)
+		}
+		clock.Advance(2 * time.Second)
+		for i := 0; i < 5; i++ {
+			v, err := restored.Get(i)
+			if err != nil {
+				t.Errorf("%s: %v", tp, err)
+			}
+			if v != fmt.Sprintf("v%d", i) {
+				t.Errorf("%s: %v != v%d", tp, v, i)
+			}
+		}
+		if _, err := restored.Get("short"); err != KeyNotFoundError {
+			t.Errorf("%s: short lived key should have expired, err: %v", tp, err)
+		}
+		if _, err := restored.Get("long"); err != nil {
+			t.Errorf("%s: %v", tp, err)
+		}
+		clock.Advance(time.Hour)
+		if _, err := restored.Get("long"); err != KeyNotFoundError {
+			t.Errorf("%s: remaining ttl should be kept, err: %v", tp, err)
+		}
+	}
+}
+
+func TestLoadRejectsCorruptSnapshot(t *testing.T) {
+	gc := New(10).LRU().Build()
+	gc.Set("key", "value")
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[len(data)/2] ^= 0xff
+	if err := New(10).LRU().Build().Load(bytes.NewReader(data)); err != SnapshotChecksumErr {
+		t.Errorf("err should be %v, not %v", SnapshotChecksumErr, err)
+	}
+	if err := New(10).LRU().Build().Load(bytes.NewReader([]byte("garbage"))); err != InvalidSnapshotErr {
+		t.Errorf("err should be %v, not %v", InvalidSnapshotErr, err)
+	}
+	buf.Reset()
+	gc.Save(&buf)
+	if err := New(10).LFU().Build().Load(&buf); err == nil {
+		t.Error("loading a LRU snapshot into a LFU cache should fail")
+	}
+}
+
+func TestLRUSaveLoadKeepsRecency(t *testing.T) {
+	gc := New(3).LRU().Build()
+	gc.Set(1, 1)
+	gc.Set(2, 2)
+	gc.Set(3, 3)
+	gc.Get(1)
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(3).LRU().Build()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored.Set(4, 4)
+	if _, err := restored.GetIFPresent(2); err != KeyNotFoundError {
+		t.Errorf("2 was the least recently used key and should be evicted")
+	}
+	if _, err := restored.GetIFPresent(1); err != nil {
+		t.Errorf("1 should survive the eviction: %v", err)
+	}
+}
+
+func TestLFUSaveLoadKeepsFrequency(t *testing.T) {
+	gc := New(3).LFU().Build()
+	gc.Set(1, 1)
+	gc.Set(2, 2)
+	gc.Set(3, 3)
+	for i := 0; i < 3; i++ {
+		gc.Get(1)
+		gc.Get(3)
+	}
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(3).LFU().Build().(*LFUCache)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if freq := restored.policy.(*lfuPolicy).elements[1].Value.(*freqEntry).freq; freq != 3 {
+		t.Errorf("%v != %v", freq, 3)
+	}
+	restored.Set(4, 4)
+	if _, err := restored.GetIFPresent(2); err != KeyNotFoundError {
+		t.Errorf("2 was the least frequently used key and should be evicted")
+	}
+}
+
+func TestARCSaveLoadKeepsLists(t *testing.T) {
+	gc := New(4).ARC().Build().(*ARC)
+	for i := 0; i < 6; i++ {
+		gc.Set(i, i)
+	}
+	gc.Get(4)
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(4).ARC().Build().(*ARC)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved, current := gc.policy.(*arcPolicy), restored.policy.(*arcPolicy)
+	if current.part != saved.part {
+		t.Errorf("%v != %v", current.part, saved.part)
+	}
+	for _, l := range []struct {
+		name           string
+		saved, current *arcList
+	}{
+		{"t1", saved.t1, current.t1},
+		{"t2", saved.t2, current.t2},
+		{"b1", saved.b1, current.b1},
+		{"b2", saved.b2, current.b2},
+	} {
+		if fmt.Sprint(arcListKeys(l.saved)) != fmt.Sprint(arcListKeys(l.current)) {
+			t.Errorf("%s: %v != %v", l.name, arcListKeys(l.current), arcListKeys(l.saved))
+		}
+	}
+}
+
+func arcListKeys(al *arcList) []interface{} {
+	var keys []interface{}
+	for e := al.l.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value)
+	}
+	return keys
+}
+
+func TestOrderedSaveLoadKeepsOrder(t *testing.T) {
+	c := New(100).BuildOrderedCache()
+	for i := 0; i < 10; i++ {
+		c.EnQueue(i, i*i)
+	}
+	c.Prepend(42, 0)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(100).BuildOrderedCache()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(restored.OrderedKeys()) != fmt.Sprint(c.OrderedKeys()) {
+		t.Errorf("%v != %v", restored.OrderedKeys(), c.OrderedKeys())
+	}
+	k, v, err := restored.GetTop()
+	if err != nil || k != 42 || v != 0 {
+		t.Errorf("unexpected head %v %v %v", k, v, err)
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Encode(v interface{}) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func (upperCodec) Decode(b []byte) (interface{}, error) {
+	return string(bytes.ToUpper(b)), nil
+}
+
+func TestSaveLoadCodec(t *testing.T) {
+	gc := New(10).ValueCodec(upperCodec{}).Build()
+	gc.Set(1, "value")
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(10).ValueCodec(upperCodec{}).Build()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := restored.Get(1); v != "VALUE" {
+		t.Errorf("%v != %v", v, "VALUE")
+	}
+}
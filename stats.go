@@ -2,6 +2,7 @@ package gcache
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 type statsAccessor interface {
@@ -9,12 +10,90 @@ type statsAccessor interface {
 	MissCount() uint64
 	LookupCount() uint64
 	HitRate() float64
+	Stats() StatsSnapshot
+	ResetStats()
 }
 
+// removalCause tells why an item left the cache.
+type removalCause int
+
+const (
+	// causeEvicted: room was needed for another item
+	causeEvicted removalCause = iota
+	// causeExpired: the item outlived its expiration time
+	causeExpired
+	// causeRemoved: Remove was called
+	causeRemoved
+	// causeDequeued: the item was taken out of an ordered cache, it is not counted
+	causeDequeued
+)
+
 // statistics
 type stats struct {
-	hitCount  uint64
-	missCount uint64
+	hitCount         uint64
+	missCount        uint64
+	evictionCount    uint64
+	expirationCount  uint64
+	removalCount     uint64
+	loadSuccessCount uint64
+	loadErrorCount   uint64
+	loaderPanicCount uint64
+	totalLoadTime    int64
+	maxLoadTime      int64
+	inFlightLoads    int64
+	negativeHitCount uint64
+}
+
+// StatsSnapshot holds the counters of a cache at the time Stats was called.
+type StatsSnapshot struct {
+	HitCount  uint64
+	MissCount uint64
+	// EvictionCount counts the items which were dropped to make room for others.
+	EvictionCount uint64
+	// ExpirationCount counts the items which were dropped because they expired.
+	ExpirationCount uint64
+	// RemovalCount counts the items which were dropped by Remove.
+	RemovalCount     uint64
+	LoadSuccessCount uint64
+	// LoadErrorCount counts the loader calls which returned an error, panics are not included.
+	LoadErrorCount   uint64
+	LoaderPanicCount uint64
+	TotalLoadTime    time.Duration
+	MaxLoadTime      time.Duration
+	// InFlightLoads is the number of loader calls running right now.
+	// It is not affected by ResetStats.
+	InFlightLoads int64
+	// NegativeHitCount counts the lookups answered with a loader error cached by NegativeCacheTTL.
+	// They are counted as misses as well.
+	NegativeHitCount uint64
+}
+
+// LookupCount returns lookup count
+func (s StatsSnapshot) LookupCount() uint64 {
+	return s.HitCount + s.MissCount
+}
+
+// HitRate returns rate for cache hitting
+func (s StatsSnapshot) HitRate() float64 {
+	total := s.LookupCount()
+	if total == 0 {
+		return 0.0
+	}
+	return float64(s.HitCount) / float64(total)
+}
+
+// LoadCount returns the number of finished loader calls
+func (s StatsSnapshot) LoadCount() uint64 {
+	return s.LoadSuccessCount + s.LoadErrorCount + s.LoaderPanicCount
+}
+
+// AverageLoadTime returns the mean time spent in the loader
+func (s StatsSnapshot) AverageLoadTime() time.Duration {
+	n := s.LoadCount()
+	if n == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(n)
 }
 
 // increment hit count
@@ -27,6 +106,48 @@ func (st *stats) IncrMissCount() uint64 {
 	return atomic.AddUint64(&st.missCount, 1)
 }
 
+// incrNegativeHitCount counts a lookup answered with a cached loader error.
+func (st *stats) incrNegativeHitCount() {
+	atomic.AddUint64(&st.negativeHitCount, 1)
+}
+
+// incrRemoval counts an item which left the cache for cause.
+func (st *stats) incrRemoval(cause removalCause) {
+	switch cause {
+	case causeEvicted:
+		atomic.AddUint64(&st.evictionCount, 1)
+	case causeExpired:
+		atomic.AddUint64(&st.expirationCount, 1)
+	case causeRemoved:
+		atomic.AddUint64(&st.removalCount, 1)
+	}
+}
+
+// startLoad marks a loader call as in flight.
+func (st *stats) startLoad() {
+	atomic.AddInt64(&st.inFlightLoads, 1)
+}
+
+// finishLoad records the outcome of a loader call started by startLoad.
+func (st *stats) finishLoad(d time.Duration, err error, panicked bool) {
+	atomic.AddInt64(&st.inFlightLoads, -1)
+	switch {
+	case panicked:
+		atomic.AddUint64(&st.loaderPanicCount, 1)
+	case err != nil:
+		atomic.AddUint64(&st.loadErrorCount, 1)
+	default:
+		atomic.AddUint64(&st.loadSuccessCount, 1)
+	}
+	atomic.AddInt64(&st.totalLoadTime, int64(d))
+	for {
+		max := atomic.LoadInt64(&st.maxLoadTime)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&st.maxLoadTime, max, int64(d)) {
+			return
+		}
+	}
+}
+
 // HitCount returns hit count
 func (st *stats) HitCount() uint64 {
 	return atomic.LoadUint64(&st.hitCount)
@@ -51,3 +172,35 @@ func (st *stats) HitRate() float64 {
 	}
 	return float64(hc) / float64(total)
 }
+
+// Stats returns a copy of all counters
+func (st *stats) Stats() StatsSnapshot {
+	return StatsSnapshot{
+		HitCount:         atomic.LoadUint64(&st.hitCount),
+		MissCount:        atomic.LoadUint64(&st.missCount),
+		EvictionCount:    atomic.LoadUint64(&st.evictionCount),
+		ExpirationCount:  atomic.LoadUint64(&st.expirationCount),
+		RemovalCount:     atomic.LoadUint64(&st.removalCount),
+		LoadSuccessCount: atomic.LoadUint64(&st.loadSuccessCount),
+		LoadErrorCount:   atomic.LoadUint64(&st.loadErrorCount),
+		LoaderPanicCount: atomic.LoadUint64(&st.loaderPanicCount),
+		TotalLoadTime:    time.Duration(atomic.LoadInt64(&st.totalLoadTime)),
+		MaxLoadTime:      time.Duration(atomic.LoadInt64(&st.maxLoadTime)),
+		InFlightLoads:    atomic.LoadInt64(&st.inFlightLoads),
+		NegativeHitCount: atomic.LoadUint64(&st.negativeHitCount),
+	}
+}
+
+// ResetStats sets all counters back to zero, except for the number of in-flight loads
+func (st *stats) ResetStats() {
+	for _, c := range []*uint64{
+		&st.hitCount, &st.missCount,
+		&st.evictionCount, &st.expirationCount, &st.removalCount,
+		&st.loadSuccessCount, &st.loadErrorCount, &st.loaderPanicCount,
+		&st.negativeHitCount,
+	} {
+		atomic.StoreUint64(c, 0)
+	}
+	atomic.StoreInt64(&st.totalLoadTime, 0)
+	atomic.StoreInt64(&st.maxLoadTime, 0)
+}
@@ -1,7 +1,9 @@
 package gcache
 
 import (
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestStats(t *testing.T) {
@@ -140,3 +142,111 @@ func TestCacheStats(t *testing.T) {
 		}
 	}
 }
+
+func TestStatsRemovalCauses(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		gc := New(2).EvictType(tp).Clock(clock).Build()
+		gc.Set("a", 1)
+		gc.Set("b", 2)
+		gc.Set("c", 3)
+		keys := gc.Keys()
+		if len(keys) != 2 {
+			t.Fatalf("%s: unexpected keys %v", tp, keys)
+		}
+		gc.SetWithExpire(keys[0], 1, time.Second)
+		clock.Advance(2 * time.Second)
+		gc.Get(keys[0])
+		gc.Remove(keys[1])
+		gc.Remove("missing")
+
+		st := gc.Stats()
+		if st.EvictionCount != 1 || st.ExpirationCount != 1 || st.RemovalCount != 1 {
+			t.Errorf("%s: unexpected stats %+v", tp, st)
+		}
+	}
+}
+
+func TestStatsOrderedCache(t *testing.T) {
+	c := New(2).BuildOrderedCache()
+	c.EnQueue(1, 1)
+	c.EnQueue(2, 2)
+	c.EnQueue(3, 3)
+	c.DeQueue()
+	c.Remove(3)
+	st := c.Stats()
+	if st.EvictionCount != 1 || st.ExpirationCount != 0 || st.RemovalCount != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestStatsLoads(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		clock := NewFakeClock()
+		release := make(chan struct{})
+		gc := New(8).EvictType(tp).Clock(clock).LoaderFunc(func(key interface{}) (interface{}, error) {
+			switch key {
+			case "error":
+				return nil, errors.New("failed")
+			case "panic":
+				panic("boom")
+			case "wait":
+				<-release
+			}
+			clock.Advance(time.Duration(len(key.(string))) * time.Millisecond)
+			return key, nil
+		}).Build()
+
+		gc.Get("a")
+		gc.Get("abc")
+		gc.Get("error")
+		gc.Get("panic")
+
+		done := make(chan struct{})
+		go func() {
+			gc.Get("wait")
+			close(done)
+		}()
+		for i := 0; gc.Stats().InFlightLoads != 1; i++ {
+			if i > 1000 {
+				t.Fatalf("%s: the load should be in flight", tp)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		<-done
+
+		st := gc.Stats()
+		if st.LoadSuccessCount != 3 || st.LoadErrorCount != 1 || st.LoaderPanicCount != 1 || st.InFlightLoads != 0 {
+			t.Errorf("%s: unexpected stats %+v", tp, st)
+		}
+		if st.TotalLoadTime != 8*time.Millisecond || st.MaxLoadTime != 4*time.Millisecond {
+			t.Errorf("%s: unexpected load times %v %v", tp, st.TotalLoadTime, st.MaxLoadTime)
+		}
+		if st.LoadCount() != 5 {
+			t.Errorf("%s: %v != %v", tp, st.LoadCount(), 5)
+		}
+
+		gc.ResetStats()
+		if st := gc.Stats(); st != (StatsSnapshot{}) {
+			t.Errorf("%s: the stats should be empty after ResetStats: %+v", tp, st)
+		}
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	gc := New(100).Shards(4).LoaderFunc(getter).Build()
+	for i := 0; i < 10; i++ {
+		gc.Get(i)
+		gc.Get(i)
+	}
+	gc.Remove(0)
+	st := gc.Stats()
+	if st.HitCount != 10 || st.MissCount != 10 || st.LoadSuccessCount != 10 || st.RemovalCount != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	gc.ResetStats()
+	if st := gc.Stats(); st.LookupCount() != 0 {
+		t.Errorf("%v != %v", st.LookupCount(), 0)
+	}
+}
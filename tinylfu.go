@@ -0,0 +1,285 @@
+package gcache
+
+import (
+	"container/list"
+)
+
+const (
+	tinyLFUWindow = iota
+	tinyLFUProbation
+	tinyLFUProtected
+)
+
+// TinyLFUCache implements W-TinyLFU. New items enter a small LRU window, when they
+// leave it they compete with the victim of the main cache for a place there, and the
+// one which was accessed more often according to a count-min sketch wins.
+// The main cache is a segmented LRU: items are promoted from probation to protected
+// on their second hit. The sketch is halved periodically, so old popularity fades.
+// Lookups only take the read lock, they are buffered and applied in batches.
+type TinyLFUCache struct {
+	policyCache
+}
+
+func newTinyLFUCache(cb *CacheBuilder) *TinyLFUCache {
+	c := &TinyLFUCache{}
+	c.setup(cb, TYPE_TINYLFU, newTinyLFUPolicy(cb.size))
+	return c
+}
+
+// tinyLFUPolicy keeps the keys in the window, probation and protected segments,
+// the most recently used one at the front of each.
+type tinyLFUPolicy struct {
+	size          int
+	windowSize    int
+	protectedSize int
+	segments      [3]*list.List
+	elements      map[interface{}]*list.Element
+	sketch        *countMinSketch
+}
+
+type tinyLFUNode struct {
+	key interface{}
+	// hash is the hash of key in the sketch
+	hash    uint64
+	segment int
+}
+
+func newTinyLFUPolicy(size int) *tinyLFUPolicy {
+	// 1% of the cache is the window, 80% of the rest is protected
+	p := &tinyLFUPolicy{size: size, windowSize: size / 100}
+	if p.windowSize < 1 {
+		p.windowSize = 1
+	}
+	p.protectedSize = (size - p.windowSize) * 8 / 10
+	return p
+}
+
+// move puts e at the front of segment seg.
+func (p *tinyLFUPolicy) move(e *list.Element, seg int) {
+	n := e.Value.(*tinyLFUNode)
+	p.segments[n.segment].Remove(e)
+	n.segment = seg
+	p.elements[n.key] = p.segments[seg].PushFront(n)
+}
+
+// OnAdd puts key into the window, the items which overflow it go to probation.
+// When the cache is full Victim makes them compete first.
+func (p *tinyLFUPolicy) OnAdd(key interface{}) {
+	n := &tinyLFUNode{key: key, hash: hashKey(key)}
+	p.sketch.increment(n.hash)
+	p.elements[key] = p.segments[tinyLFUWindow].PushFront(n)
+	for window := p.segments[tinyLFUWindow]; window.Len() > p.windowSize; {
+		p.move(window.Back(), tinyLFUProbation)
+	}
+}
+
+// OnAccess is called when the value of key is replaced, lookups go through onLookups.
+func (p *tinyLFUPolicy) OnAccess(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		p.access(e)
+	}
+}
+
+// onLookups counts the buffered lookups and moves the keys which are stored.
+// Misses are counted too, a key which is asked for often deserves a place.
+func (p *tinyLFUPolicy) onLookups(keys []interface{}) {
+	for _, key := range keys {
+		if e, ok := p.elements[key]; ok {
+			p.access(e)
+		} else {
+			p.sketch.increment(hashKey(key))
+		}
+	}
+}
+
+// access records a hit on e and moves it like a segmented LRU does.
+func (p *tinyLFUPolicy) access(e *list.Element) {
+	n := e.Value.(*tinyLFUNode)
+	p.sketch.increment(n.hash)
+	if n.segment == tinyLFUProbation {
+		p.move(e, tinyLFUProtected)
+		p.demote()
+		return
+	}
+	p.segments[n.segment].MoveToFront(e)
+}
+
+// demote moves the items which overflow the protected segment back to probation.
+func (p *tinyLFUPolicy) demote() {
+	for protected := p.segments[tinyLFUProtected]; protected.Len() > p.protectedSize; {
+		p.move(protected.Back(), tinyLFUProbation)
+	}
+}
+
+func (p *tinyLFUPolicy) OnRemove(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		p.segments[e.Value.(*tinyLFUNode).segment].Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim makes the candidate which leaves the full window compete with the probation
+// victim, the less frequent one is evicted and the winner stays in probation.
+// Without a candidate the probation, window and protected segments are emptied from their tails.
+func (p *tinyLFUPolicy) Victim() (interface{}, bool) {
+	window, probation, protected := p.segments[tinyLFUWindow], p.segments[tinyLFUProbation], p.segments[tinyLFUProtected]
+	if window.Len() >= p.windowSize {
+		cand := window.Back()
+		victim := probation.Back()
+		if victim == nil {
+			victim = protected.Back()
+		}
+		if victim == nil {
+			return cand.Value.(*tinyLFUNode).key, true
+		}
+		candNode, victimNode := cand.Value.(*tinyLFUNode), victim.Value.(*tinyLFUNode)
+		if p.sketch.estimate(candNode.hash) > p.sketch.estimate(victimNode.hash) {
+			p.move(cand, tinyLFUProbation)
+			return victimNode.key, true
+		}
+		return candNode.key, true
+	}
+	for _, l := range []*list.List{probation, window, protected} {
+		if e := l.Back(); e != nil {
+			return e.Value.(*tinyLFUNode).key, true
+		}
+	}
+	return nil, false
+}
+
+func (p *tinyLFUPolicy) Reset() {
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+	p.elements = make(map[interface{}]*list.Element)
+	p.sketch = newCountMinSketch(p.size)
+}
+
+// save keeps the segment and recency order of every item, and the estimated frequency of the key.
+func (p *tinyLFUPolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	var segments [3][]snapshotEntry
+	for i, l := range p.segments {
+		for e := l.Front(); e != nil; e = e.Next() {
+			n := e.Value.(*tinyLFUNode)
+			se := entry(n.key)
+			se.freq = uint64(p.sketch.estimate(n.hash))
+			segments[i] = append(segments[i], se)
+		}
+	}
+	return func(sw *snapshotWriter) error {
+		for _, entries := range segments {
+			if err := sw.writeEntries(entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (p *tinyLFUPolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	var segments [3][]snapshotEntry
+	for i := range segments {
+		var err error
+		if segments[i], err = sr.readEntries(); err != nil {
+			return nil, err
+		}
+	}
+	return func(add func(e snapshotEntry) bool) {
+		// the protected items are the most valuable ones, they get room first
+		for _, seg := range []int{tinyLFUProtected, tinyLFUProbation, tinyLFUWindow} {
+			for _, e := range segments[seg] {
+				if !add(e) {
+					continue
+				}
+				n := &tinyLFUNode{key: e.key, hash: hashKey(e.key), segment: seg}
+				p.elements[e.key] = p.segments[seg].PushBack(n)
+				for i := uint64(0); i < e.freq; i++ {
+					p.sketch.increment(n.hash)
+				}
+			}
+		}
+		// a snapshot of a larger cache may not fit into the segments of this one
+		p.demote()
+		for window := p.segments[tinyLFUWindow]; window.Len() > p.windowSize; {
+			p.move(window.Back(), tinyLFUProbation)
+		}
+	}, nil
+}
+
+const (
+	sketchDepth   = 4
+	sketchMaxFreq = 15
+)
+
+// countMinSketch estimates how often a key was seen with 4 rows of small counters.
+// Once the number of increments reaches 10 times its width, every counter is halved.
+type countMinSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newCountMinSketch(size int) *countMinSketch {
+	width := 16
+	for width < size {
+		width <<= 1
+	}
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		sampleSize: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the counter of key in row i.
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	h += uint64(i+1) * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h & s.mask
+}
+
+// increment counts the key with hash h.
+func (s *countMinSketch) increment(h uint64) {
+	added := false
+	for i := range s.rows {
+		j := s.index(h, i)
+		if s.rows[i][j] < sketchMaxFreq {
+			s.rows[i][j]++
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+// estimate returns how often the key with hash h was counted.
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	est := uint8(sketchMaxFreq)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < est {
+			est = v
+		}
+	}
+	return est
+}
+
+// age halves every counter, so keys which are not popular anymore can be replaced.
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,148 @@
+package gcache
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestTinyLFUHitRateWithScans(t *testing.T) {
+	trace := hotSetWithScans(100000)
+	lru := replayHitRate(New(150).LRU().Build(), trace)
+	tlfu := replayHitRate(New(150).TinyLFU().Build(), trace)
+	if tlfu <= lru {
+		t.Errorf("tinylfu hit rate %.3f should be higher than lru %.3f", tlfu, lru)
+	}
+	// nearly every access of the hot set should be a hit
+	if tlfu < 0.35 {
+		t.Errorf("tinylfu hit rate %.3f is too low", tlfu)
+	}
+}
+
+func TestTinyLFUPromotion(t *testing.T) {
+	gc := New(200).TinyLFU().Build().(*TinyLFUCache)
+	for i := 0; i < 3; i++ {
+		gc.Set(i, i)
+	}
+	p := gc.policy.(*tinyLFUPolicy)
+	window, probation := p.segments[tinyLFUWindow], p.segments[tinyLFUProbation]
+	if window.Len() != 2 || probation.Len() != 1 {
+		t.Fatalf("window %v, probation %v", window.Len(), probation.Len())
+	}
+	if _, err := gc.Get(0); err != nil {
+		t.Fatal(err)
+	}
+	// the lookup is buffered until the cache changes
+	gc.Set(3, 3)
+	if n := p.elements[0].Value.(*tinyLFUNode); n.segment != tinyLFUProtected {
+		t.Errorf("a hit in probation should promote the item, segment is %v", n.segment)
+	}
+}
+
+func TestTinyLFUFullLookupBuffer(t *testing.T) {
+	gc := New(200).TinyLFU().Build().(*TinyLFUCache)
+	for i := 0; i < 3; i++ {
+		gc.Set(i, i)
+	}
+	// the lookup which fills the buffer applies it
+	for i := 0; i < lookupBufferSize; i++ {
+		gc.Get(0)
+	}
+	p := gc.policy.(*tinyLFUPolicy)
+	if n := p.elements[0].Value.(*tinyLFUNode); n.segment != tinyLFUProtected {
+		t.Errorf("segment %v != %v", n.segment, tinyLFUProtected)
+	}
+	if n := p.sketch.estimate(hashKey(0)); n != sketchMaxFreq {
+		t.Errorf("%v != %v", n, sketchMaxFreq)
+	}
+}
+
+func TestTinyLFUConcurrentGet(t *testing.T) {
+	gc := New(64).TinyLFU().Build()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := (i * (g + 1)) % 100
+				if _, err := gc.Get(k); err == KeyNotFoundError {
+					gc.Set(k, k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := gc.Len(); n > 64 {
+		t.Errorf("%v > %v", n, 64)
+	}
+}
+
+func TestTinyLFURejectsRareCandidate(t *testing.T) {
+	gc := New(100).TinyLFU().Build()
+	for i := 0; i < 100; i++ {
+		gc.Set(i, i)
+		for j := 0; j < 3; j++ {
+			gc.Get(i)
+		}
+	}
+	for i := 100; i < 200; i++ {
+		gc.Set(i, i)
+	}
+	if n := gc.Len(); n != 100 {
+		t.Fatalf("%v != %v", n, 100)
+	}
+	kept := 0
+	for i := 0; i < 100; i++ {
+		if _, err := gc.GetIFPresent(i); err == nil {
+			kept++
+		}
+	}
+	if kept < 98 {
+		t.Errorf("only %v frequent keys were kept", kept)
+	}
+}
+
+func TestCountMinSketchAging(t *testing.T) {
+	s := newCountMinSketch(16)
+	for i := 0; i < 20; i++ {
+		s.increment(hashKey("a"))
+	}
+	if n := s.estimate(hashKey("a")); n != sketchMaxFreq {
+		t.Errorf("%v != %v", n, sketchMaxFreq)
+	}
+	s.age()
+	if n := s.estimate(hashKey("a")); n != sketchMaxFreq/2 {
+		t.Errorf("%v != %v", n, sketchMaxFreq/2)
+	}
+	if n := s.estimate(hashKey("b")); n != 0 {
+		t.Errorf("%v != %v", n, 0)
+	}
+}
+
+func TestTinyLFUSaveLoadKeepsSegments(t *testing.T) {
+	gc := New(10).TinyLFU().Build().(*TinyLFUCache)
+	for i := 0; i < 10; i++ {
+		gc.Set(i, i)
+	}
+	gc.Get(0)
+	gc.Get(0)
+	var buf bytes.Buffer
+	if err := gc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(10).TinyLFU().Build().(*TinyLFUCache)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p := restored.policy.(*tinyLFUPolicy)
+	if n := len(p.elements); n != 10 {
+		t.Errorf("%v != %v", n, 10)
+	}
+	if n := p.elements[0].Value.(*tinyLFUNode); n.segment != tinyLFUProtected {
+		t.Errorf("segment %v != %v", n.segment, tinyLFUProtected)
+	}
+	if a, b := p.sketch.estimate(hashKey(0)), gc.policy.(*tinyLFUPolicy).sketch.estimate(hashKey(0)); a != b {
+		t.Errorf("frequency %v != %v", a, b)
+	}
+}
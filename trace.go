@@ -0,0 +1,233 @@
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// traceMagic starts every trace, followed by the format version.
+var traceMagic = []byte("GCTRACE")
+
+const traceVersion = 1
+
+// TraceOp is the cache operation of a TraceRecord.
+type TraceOp uint8
+
+const (
+	TraceGet TraceOp = iota + 1
+	TraceGetIFPresent
+	TraceSet
+	TraceRemove
+)
+
+// TraceOutcome tells how a lookup or a removal went. Set records have no outcome.
+type TraceOutcome uint8
+
+const (
+	// TraceHit is a lookup which found the key, or a removal of a stored key.
+	TraceHit TraceOutcome = iota + 1
+	// TraceMiss is a lookup which found no value, or a removal of a missing key.
+	TraceMiss
+	// TraceLoad is a lookup which missed and got its value from the loader.
+	TraceLoad
+)
+
+// TraceRecord is one access written by a TraceRecorder.
+type TraceRecord struct {
+	Time    time.Time
+	Op      TraceOp
+	Outcome TraceOutcome
+	// KeyHash is the hash the cache uses to pick a shard for the key, the key itself is not recorded.
+	KeyHash uint64
+	// Size of the value, it is 0 unless the cache was built with TraceValueSize.
+	Size int64
+}
+
+// TraceRecorder logs every Get, GetCtx, GetIFPresent, Set, SetWithExpire and Remove
+// of the cache to w. Records are buffered, Close flushes them. A failed write stops the
+// recording, the error is returned by Close. Read the trace back with NewTraceReader.
+// The recorder is shared by the shards of a sharded cache, ordered caches do not support it.
+func (cb *CacheBuilder) TraceRecorder(w io.Writer) *CacheBuilder {
+	cb.traceWriter = w
+	return cb
+}
+
+// TraceSampling records only the accesses to a share rate of the keys, e.g. 0.01 for one in
+// a hundred. Keys are picked by their hash, so every access of a sampled key is recorded.
+// A cache of size n sees a sampled trace like a cache of size n*rate sees the full one.
+func (cb *CacheBuilder) TraceSampling(rate float64) *CacheBuilder {
+	cb.traceRate = rate
+	return cb
+}
+
+// TraceValueSize sets the function which tells the size of a value in the trace, e.g. in bytes.
+// It is called with the value given to Set or returned by a lookup, Remove records have no size.
+func (cb *CacheBuilder) TraceValueSize(size Weigher) *CacheBuilder {
+	cb.traceSize = size
+	return cb
+}
+
+// traceRecorder encodes the accesses of a cache. A record is its op and outcome in one byte,
+// the time since the previous record in nanoseconds as a varint, the key hash in 8 bytes
+// and the value size as an uvarint.
+type traceRecorder struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	last int64
+	err  error
+	// keys are sampled if the top 53 bits of their hash are below threshold
+	threshold uint64
+	sampleAll bool
+	size      Weigher
+	buf       [1 + 2*binary.MaxVarintLen64 + 8]byte
+}
+
+func newTraceRecorder(cb *CacheBuilder) *traceRecorder {
+	r := &traceRecorder{
+		w:         bufio.NewWriter(cb.traceWriter),
+		sampleAll: cb.traceRate <= 0 || cb.traceRate >= 1,
+		threshold: uint64(cb.traceRate * (1 << 53)),
+		size:      cb.traceSize,
+	}
+	r.w.Write(traceMagic)
+	r.w.WriteByte(traceVersion)
+	return r
+}
+
+func (r *traceRecorder) record(now time.Time, op TraceOp, key interface{}, outcome TraceOutcome, value interface{}) {
+	h := hashKey(key)
+	if !r.sampleAll && h>>11 >= r.threshold {
+		return
+	}
+	var size int64
+	if r.size != nil && op != TraceRemove && outcome != TraceMiss {
+		if size = r.size(key, value); size < 0 {
+			size = 0
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	t := now.UnixNano()
+	b := r.buf[:0]
+	b = append(b, byte(op)<<4|byte(outcome))
+	b = binary.AppendVarint(b, t-r.last)
+	b = binary.LittleEndian.AppendUint64(b, h)
+	b = binary.AppendUvarint(b, uint64(size))
+	r.last = t
+	_, r.err = r.w.Write(b)
+}
+
+func (r *traceRecorder) flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.w.Flush()
+	return r.err
+}
+
+// trace records an operation, it does nothing unless the cache has a TraceRecorder.
+func (c *baseCache) trace(op TraceOp, key interface{}, outcome TraceOutcome, value interface{}) {
+	if c.recorder != nil {
+		c.recorder.record(c.clock.Now(), op, key, outcome, value)
+	}
+}
+
+// traceLookup records a lookup, loaded tells whether the value was not stored and the loader was asked for it.
+func (c *baseCache) traceLookup(op TraceOp, key, value interface{}, err error, loaded bool) {
+	if c.recorder == nil {
+		return
+	}
+	outcome := TraceHit
+	if loaded {
+		outcome = TraceLoad
+		if err != nil {
+			outcome = TraceMiss
+		}
+	}
+	c.trace(op, key, outcome, value)
+}
+
+// traceRemove records a removal, ok tells whether the key was stored.
+func (c *baseCache) traceRemove(key interface{}, ok bool) {
+	outcome := TraceMiss
+	if ok {
+		outcome = TraceHit
+	}
+	c.trace(TraceRemove, key, outcome, nil)
+}
+
+// ErrInvalidTrace is returned by TraceReader for data which was not written by a TraceRecorder.
+var ErrInvalidTrace = errors.New("gcache: invalid trace")
+
+// TraceReader reads the records written by a TraceRecorder.
+type TraceReader struct {
+	r    *bufio.Reader
+	last int64
+}
+
+// NewTraceReader checks the header of the trace in r and returns a reader of its records.
+func NewTraceReader(r io.Reader) (*TraceReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(traceMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidTrace
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(traceMagic)], traceMagic) || header[len(traceMagic)] != traceVersion {
+		return nil, ErrInvalidTrace
+	}
+	return &TraceReader{r: br}, nil
+}
+
+// Read returns the next record, or io.EOF once every record was read.
+func (tr *TraceReader) Read() (TraceRecord, error) {
+	kind, err := tr.r.ReadByte()
+	if err != nil {
+		return TraceRecord{}, err
+	}
+	delta, err := binary.ReadVarint(tr.r)
+	if err != nil {
+		return TraceRecord{}, truncated(err)
+	}
+	var h [8]byte
+	if _, err := io.ReadFull(tr.r, h[:]); err != nil {
+		return TraceRecord{}, truncated(err)
+	}
+	size, err := binary.ReadUvarint(tr.r)
+	if err != nil {
+		return TraceRecord{}, truncated(err)
+	}
+	rec := TraceRecord{
+		Op:      TraceOp(kind >> 4),
+		Outcome: TraceOutcome(kind & 0x0f),
+		KeyHash: binary.LittleEndian.Uint64(h[:]),
+		Size:    int64(size),
+	}
+	if rec.Op < TraceGet || rec.Op > TraceRemove || rec.Outcome > TraceLoad {
+		return TraceRecord{}, ErrInvalidTrace
+	}
+	tr.last += delta
+	rec.Time = time.Unix(0, tr.last)
+	return rec, nil
+}
+
+// truncated turns the end of the data in the middle of a record into io.ErrUnexpectedEOF.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
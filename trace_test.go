@@ -0,0 +1,211 @@
+package gcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func readTraceRecords(t *testing.T, r io.Reader) []TraceRecord {
+	tr, err := NewTraceReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []TraceRecord
+	for {
+		rec, err := tr.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestTraceRecorder(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var buf bytes.Buffer
+		clock := NewFakeClock()
+		gc := New(10).
+			EvictType(tp).
+			Clock(clock).
+			LoaderFunc(func(key interface{}) (interface{}, error) {
+				if key == "missing" {
+					return nil, errors.New("not found")
+				}
+				return "loaded", nil
+			}).
+			TraceRecorder(&buf).
+			TraceValueSize(weighString).
+			Build()
+
+		start := clock.Now()
+		gc.Set("a", "aa")
+		clock.Advance(time.Second)
+		gc.Get("a")
+		gc.Get("b")
+		gc.Get("missing")
+		clock.Advance(time.Second)
+		gc.GetIFPresent("a")
+		gc.Remove("a")
+		gc.Remove("a")
+		if buf.Len() != 0 {
+			t.Fatalf("%s: expected the records to be buffered until Close", tp)
+		}
+		if err := gc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []TraceRecord{
+			{start, TraceSet, 0, hashKey("a"), 2},
+			{start.Add(time.Second), TraceGet, TraceHit, hashKey("a"), 2},
+			{start.Add(time.Second), TraceGet, TraceLoad, hashKey("b"), 6},
+			{start.Add(time.Second), TraceGet, TraceMiss, hashKey("missing"), 0},
+			{start.Add(2 * time.Second), TraceGetIFPresent, TraceHit, hashKey("a"), 2},
+			{start.Add(2 * time.Second), TraceRemove, TraceHit, hashKey("a"), 0},
+			{start.Add(2 * time.Second), TraceRemove, TraceMiss, hashKey("a"), 0},
+		}
+		records := readTraceRecords(t, &buf)
+		if len(records) != len(expected) {
+			t.Fatalf("%s: expected %d records, got %d", tp, len(expected), len(records))
+		}
+		for i, rec := range records {
+			if !rec.Time.Equal(expected[i].Time) || rec.Op != expected[i].Op || rec.Outcome != expected[i].Outcome ||
+				rec.KeyHash != expected[i].KeyHash || rec.Size != expected[i].Size {
+				t.Errorf("%s: record %d: expected %+v, got %+v", tp, i, expected[i], rec)
+			}
+		}
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	var buf bytes.Buffer
+	gc := New(100).LRU().TraceRecorder(&buf).TraceSampling(0.1).Build()
+	for i := 0; i < 2000; i++ {
+		gc.Set(i, i)
+		gc.Get(i)
+	}
+	gc.Close()
+
+	counts := map[uint64]int{}
+	for _, rec := range readTraceRecords(t, &buf) {
+		counts[rec.KeyHash]++
+	}
+	if len(counts) < 100 || len(counts) > 300 {
+		t.Errorf("expected about 200 sampled keys, got %d", len(counts))
+	}
+	for h, n := range counts {
+		if n != 2 {
+			t.Errorf("expected both accesses of key hash %x, got %d", h, n)
+		}
+	}
+}
+
+func TestTraceRecorderSharded(t *testing.T) {
+	var buf bytes.Buffer
+	gc := New(100).LRU().Shards(4).TraceRecorder(&buf).Build()
+	for i := 0; i < 50; i++ {
+		gc.Set(fmt.Sprint(i), i)
+		gc.Get(fmt.Sprint(i))
+	}
+	if err := gc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readTraceRecords(t, &buf)); n != 100 {
+		t.Errorf("expected 100 records, got %d", n)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTraceRecorderWriteError(t *testing.T) {
+	gc := New(10).LRU().TraceRecorder(failingWriter{}).Build()
+	gc.Set("a", 1)
+	if err := gc.Close(); err == nil {
+		t.Error("expected the write error from Close")
+	}
+	if v, err := gc.Get("a"); err != nil || v != 1 {
+		t.Errorf("expected the cache to keep working, got %v, %v", v, err)
+	}
+}
+
+func TestTraceReaderInvalid(t *testing.T) {
+	if _, err := NewTraceReader(bytes.NewReader([]byte("not a trace"))); err != ErrInvalidTrace {
+		t.Errorf("expected ErrInvalidTrace, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	gc := New(10).LRU().TraceRecorder(&buf).Build()
+	gc.Set("a", 1)
+	gc.Close()
+	tr, err := NewTraceReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for a truncated record, got %v", err)
+	}
+}
+
+func TestTypedTraceRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	tc := NewTyped[string, string](10).
+		LRU().
+		TraceRecorder(&buf).
+		TraceValueSize(func(k, v string) int64 { return int64(len(v)) }).
+		Build()
+	tc.Set("a", "abc")
+	tc.Get("a")
+	tc.Close()
+
+	records := readTraceRecords(t, &buf)
+	if len(records) != 2 || records[1].Outcome != TraceHit || records[1].Size != 3 {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestTraceRecorderSkipsKeys(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var buf bytes.Buffer
+		gc := New(10).EvictType(tp).TraceRecorder(&buf).Build()
+		gc.Set("a", 1)
+		gc.Keys()
+		gc.GetALL()
+		gc.Len()
+		gc.Close()
+		if n := len(readTraceRecords(t, &buf)); n != 1 {
+			t.Errorf("%s: expected only the Set to be recorded, got %d records", tp, n)
+		}
+	}
+}
+
+func TestTraceRecorderOrderedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("BuildOrderedCache should panic")
+		}
+	}()
+	New(10).TraceRecorder(&bytes.Buffer{}).BuildOrderedCache()
+}
+
+func TestTraceRecorderNotSharedByBuilds(t *testing.T) {
+	var buf bytes.Buffer
+	cb := New(10).LRU().TraceRecorder(&buf)
+	first := cb.Build()
+	second := cb.Build()
+	if first.(*LRUCache).recorder == second.(*LRUCache).recorder {
+		t.Error("every Build should get its own recorder")
+	}
+	if cb.recorder != nil {
+		t.Error("the builder should not keep the recorder")
+	}
+}
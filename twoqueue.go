@@ -0,0 +1,174 @@
+package gcache
+
+import (
+	"container/list"
+)
+
+// TwoQueueCache implements the full 2Q algorithm. New items enter a FIFO queue (A1in).
+// When they are evicted from it their keys are remembered in a ghost queue (A1out),
+// and a key which is set again while it is remembered goes to the main LRU queue (Am).
+// Items which are used only once never reach Am, which makes the cache scan resistant.
+type TwoQueueCache struct {
+	policyCache
+}
+
+func newTwoQueueCache(cb *CacheBuilder) *TwoQueueCache {
+	c := &TwoQueueCache{}
+	c.setup(cb, TYPE_2Q, newTwoQueuePolicy(cb.size))
+	return c
+}
+
+// twoQueuePolicy keeps the keys in A1in (recent) and Am (frequent), the newest one at the front.
+type twoQueuePolicy struct {
+	recent     *list.List
+	frequent   *list.List
+	elements   map[interface{}]*list.Element
+	ghost      *list.List
+	ghostItems map[interface{}]*list.Element
+	recentSize int
+	ghostSize  int
+}
+
+type twoQueueNode struct {
+	key      interface{}
+	frequent bool
+}
+
+func newTwoQueuePolicy(size int) *twoQueuePolicy {
+	// the sizes suggested by the paper: A1in holds 25% of the items, A1out remembers 50%
+	p := &twoQueuePolicy{recentSize: size / 4, ghostSize: size / 2}
+	if p.recentSize < 1 {
+		p.recentSize = 1
+	}
+	if p.ghostSize < 1 {
+		p.ghostSize = 1
+	}
+	return p
+}
+
+func (p *twoQueuePolicy) queue(n *twoQueueNode) *list.List {
+	if n.frequent {
+		return p.frequent
+	}
+	return p.recent
+}
+
+// OnAdd puts key into A1in, or into Am if it is remembered in A1out.
+func (p *twoQueuePolicy) OnAdd(key interface{}) {
+	n := &twoQueueNode{key: key}
+	if g, ok := p.ghostItems[key]; ok {
+		p.ghost.Remove(g)
+		delete(p.ghostItems, key)
+		n.frequent = true
+	}
+	p.elements[key] = p.queue(n).PushFront(n)
+}
+
+// OnAccess moves key to the front of Am, A1in is a FIFO so only hits in Am change the order.
+func (p *twoQueuePolicy) OnAccess(key interface{}) {
+	if e, ok := p.elements[key]; ok && e.Value.(*twoQueueNode).frequent {
+		p.frequent.MoveToFront(e)
+	}
+}
+
+func (p *twoQueuePolicy) OnRemove(key interface{}) {
+	if e, ok := p.elements[key]; ok {
+		p.queue(e.Value.(*twoQueueNode)).Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// Victim empties A1in while it holds more than its share, its keys are remembered in A1out.
+// Otherwise the least recently used item of Am goes.
+func (p *twoQueuePolicy) Victim() (interface{}, bool) {
+	if e := p.recent.Back(); e != nil && (p.recent.Len() > p.recentSize || p.frequent.Len() == 0) {
+		key := e.Value.(*twoQueueNode).key
+		p.remember(key)
+		return key, true
+	}
+	if e := p.frequent.Back(); e != nil {
+		return e.Value.(*twoQueueNode).key, true
+	}
+	return nil, false
+}
+
+// remember adds key to A1out, forgetting the oldest key if it is full.
+func (p *twoQueuePolicy) remember(key interface{}) {
+	p.ghostItems[key] = p.ghost.PushFront(key)
+	for p.ghost.Len() > p.ghostSize {
+		e := p.ghost.Back()
+		p.ghost.Remove(e)
+		delete(p.ghostItems, e.Value)
+	}
+}
+
+func (p *twoQueuePolicy) Reset() {
+	p.recent = list.New()
+	p.frequent = list.New()
+	p.elements = make(map[interface{}]*list.Element)
+	p.ghost = list.New()
+	p.ghostItems = make(map[interface{}]*list.Element)
+}
+
+// save keeps the Am, A1in and A1out queues.
+func (p *twoQueuePolicy) save(entry func(key interface{}) snapshotEntry) func(sw *snapshotWriter) error {
+	var queues [2][]snapshotEntry
+	for i, l := range []*list.List{p.frequent, p.recent} {
+		for e := l.Front(); e != nil; e = e.Next() {
+			queues[i] = append(queues[i], entry(e.Value.(*twoQueueNode).key))
+		}
+	}
+	ghost := make([]interface{}, 0, p.ghost.Len())
+	for e := p.ghost.Front(); e != nil; e = e.Next() {
+		ghost = append(ghost, e.Value)
+	}
+	return func(sw *snapshotWriter) error {
+		for _, entries := range queues {
+			if err := sw.writeEntries(entries); err != nil {
+				return err
+			}
+		}
+		return sw.writeKeys(ghost)
+	}
+}
+
+// load restores the queues, if the snapshot holds more items than the cache size A1in items are dropped first.
+func (p *twoQueuePolicy) load(sr *snapshotReader) (func(add func(e snapshotEntry) bool), error) {
+	frequent, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	recent, err := sr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	ghost, err := sr.readKeys()
+	if err != nil {
+		return nil, err
+	}
+	return func(add func(e snapshotEntry) bool) {
+		for _, q := range []struct {
+			entries  []snapshotEntry
+			frequent bool
+		}{{frequent, true}, {recent, false}} {
+			for _, e := range q.entries {
+				if add(e) {
+					n := &twoQueueNode{key: e.key, frequent: q.frequent}
+					p.elements[e.key] = p.queue(n).PushBack(n)
+				}
+			}
+		}
+		for _, key := range ghost {
+			if p.ghost.Len() >= p.ghostSize {
+				break
+			}
+			if _, ok := p.elements[key]; ok {
+				continue
+			}
+			if _, ok := p.ghostItems[key]; ok {
+				continue
+			}
+			p.ghostItems[key] = p.ghost.PushBack(key)
+		}
+	}, nil
+}
@@ -0,0 +1,47 @@
+package gcache
+
+import (
+	"testing"
+)
+
+func TestTwoQueueHitRateWithScans(t *testing.T) {
+	trace := zipfWithScans(100000)
+	lru := replayHitRate(New(100).LRU().Build(), trace)
+	twoq := replayHitRate(New(100).TwoQueue().Build(), trace)
+	if twoq <= lru {
+		t.Errorf("2q hit rate %.3f should be higher than lru %.3f", twoq, lru)
+	}
+}
+
+func TestTwoQueueGhostHit(t *testing.T) {
+	gc := New(8).TwoQueue().Build().(*TwoQueueCache)
+	for i := 0; i < 10; i++ {
+		gc.Set(i, i)
+	}
+	// 0 and 1 were evicted from A1in and are remembered in A1out
+	p := gc.policy.(*twoQueuePolicy)
+	if _, ok := p.ghostItems[0]; !ok {
+		t.Fatal("an evicted key should be remembered")
+	}
+	gc.Set(0, 0)
+	if _, ok := p.ghostItems[0]; ok {
+		t.Error("a key which is set again should leave A1out")
+	}
+	if n := p.elements[0].Value.(*twoQueueNode); !n.frequent {
+		t.Error("a remembered key should go to Am")
+	}
+}
+
+func TestTwoQueueRecentHitKeepsOrder(t *testing.T) {
+	gc := New(4).TwoQueue().Build()
+	gc.Set(0, 0)
+	gc.Set(1, 1)
+	gc.Get(0)
+	gc.Set(2, 2)
+	gc.Set(3, 3)
+	gc.Set(4, 4)
+	// A1in is a FIFO, the hit does not save 0 from eviction
+	if _, err := gc.GetIFPresent(0); err != KeyNotFoundError {
+		t.Errorf("%v != %v", err, KeyNotFoundError)
+	}
+}
@@ -0,0 +1,725 @@
+package gcache
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type (
+	TypedLoaderFunc[K comparable, V any]          func(K) (V, error)
+	TypedLoaderExpireFunc[K comparable, V any]    func(K) (V, *time.Duration, error)
+	TypedLoaderCtxFunc[K comparable, V any]       func(context.Context, K) (V, error)
+	TypedLoaderCtxExpireFunc[K comparable, V any] func(context.Context, K) (V, *time.Duration, error)
+	TypedEvictedFunc[K comparable, V any]         func(K, V)
+	TypedPurgeVisitorFunc[K comparable, V any]    func(K, V)
+	TypedAddedFunc[K comparable, V any]           func(K, V)
+	TypedSerializeFunc[K comparable, V any]       func(K, V) (interface{}, error)
+	TypedDeserializeFunc[K comparable, V any]     func(K, interface{}) (V, error)
+	TypedExpiredFunction[K comparable]            func(K) bool
+	TypedSortKeysFunction[K comparable, V any]    func([]K, []V, func(K) (V, bool)) ([]K, bool)
+	TypedSearchCompareFunction[V any]             func(value V, anotherValue V) int
+	TypedWeigher[K comparable, V any]             func(K, V) int64
+	TypedNegativeCacheFunc[K comparable]          func(K, error) bool
+	TypedBulkLoaderFunc[K comparable, V any]      func([]K) (map[K]V, error)
+	TypedComputeFunc[V any]                       func(old V, exists bool) (newValue V, remove bool)
+)
+
+// TypedCacheBuilder builds caches whose keys and values are statically typed.
+// It configures an ordinary CacheBuilder underneath, so every eviction type
+// behaves exactly like its interface{} counterpart.
+type TypedCacheBuilder[K comparable, V any] struct {
+	cb *CacheBuilder
+	// serialized is set by SerializeFunc, the callbacks then get stored values,
+	// which are turned back into V values with deserializeFunc
+	serialized      bool
+	deserializeFunc TypedDeserializeFunc[K, V]
+	// unkeyed is set by the callbacks which get values without their keys
+	unkeyed bool
+}
+
+// NewTyped returns a builder for a cache of the given size holding K keys and V values.
+func NewTyped[K comparable, V any](size int) *TypedCacheBuilder[K, V] {
+	return &TypedCacheBuilder[K, V]{cb: New(size)}
+}
+
+func (tb *TypedCacheBuilder[K, V]) Clock(clock Clock) *TypedCacheBuilder[K, V] {
+	tb.cb.Clock(clock)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) EvictType(tp string) *TypedCacheBuilder[K, V] {
+	tb.cb.EvictType(tp)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) Simple() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_SIMPLE)
+}
+
+func (tb *TypedCacheBuilder[K, V]) LRU() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_LRU)
+}
+
+func (tb *TypedCacheBuilder[K, V]) LFU() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_LFU)
+}
+
+func (tb *TypedCacheBuilder[K, V]) ARC() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_ARC)
+}
+
+func (tb *TypedCacheBuilder[K, V]) TinyLFU() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_TINYLFU)
+}
+
+func (tb *TypedCacheBuilder[K, V]) TwoQueue() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_2Q)
+}
+
+func (tb *TypedCacheBuilder[K, V]) SLRU() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_SLRU)
+}
+
+func (tb *TypedCacheBuilder[K, V]) SIEVE() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_SIEVE)
+}
+
+func (tb *TypedCacheBuilder[K, V]) S3FIFO() *TypedCacheBuilder[K, V] {
+	return tb.EvictType(TYPE_S3FIFO)
+}
+
+// Policy builds a cache which evicts items following p, see CacheBuilder.Policy.
+func (tb *TypedCacheBuilder[K, V]) Policy(p EvictionPolicy) *TypedCacheBuilder[K, V] {
+	tb.cb.Policy(p)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) Shards(n int) *TypedCacheBuilder[K, V] {
+	tb.cb.Shards(n)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) ShardSize(size int) *TypedCacheBuilder[K, V] {
+	tb.cb.ShardSize(size)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) KeyCodec(codec Codec) *TypedCacheBuilder[K, V] {
+	tb.cb.KeyCodec(codec)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) ValueCodec(codec Codec) *TypedCacheBuilder[K, V] {
+	tb.cb.ValueCodec(codec)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) CleanupInterval(interval time.Duration) *TypedCacheBuilder[K, V] {
+	tb.cb.CleanupInterval(interval)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) Weigher(weigher TypedWeigher[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.Weigher(func(k, v interface{}) int64 {
+		return weigher(typedKey[K](k), tb.storedValue(k, v))
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) RefreshAfter(d time.Duration) *TypedCacheBuilder[K, V] {
+	tb.cb.RefreshAfter(d)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) NegativeCacheTTL(d time.Duration) *TypedCacheBuilder[K, V] {
+	tb.cb.NegativeCacheTTL(d)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) NegativeCacheFunc(negativeCacheFunc TypedNegativeCacheFunc[K]) *TypedCacheBuilder[K, V] {
+	tb.cb.NegativeCacheFunc(func(k interface{}, err error) bool {
+		return negativeCacheFunc(typedKey[K](k), err)
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) TraceRecorder(w io.Writer) *TypedCacheBuilder[K, V] {
+	tb.cb.TraceRecorder(w)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) TraceSampling(rate float64) *TypedCacheBuilder[K, V] {
+	tb.cb.TraceSampling(rate)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) TraceValueSize(size TypedWeigher[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.TraceValueSize(func(k, v interface{}) int64 {
+		return size(typedKey[K](k), typedValue[V](v))
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) MaxWeight(maxWeight int64) *TypedCacheBuilder[K, V] {
+	tb.cb.MaxWeight(maxWeight)
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) Expiration(expiration time.Duration) *TypedCacheBuilder[K, V] {
+	tb.cb.Expiration(expiration)
+	return tb
+}
+
+// Set a loader function.
+// loaderFunc: create a new value with this function if cached value is expired.
+func (tb *TypedCacheBuilder[K, V]) LoaderFunc(loaderFunc TypedLoaderFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.LoaderFunc(func(k interface{}) (interface{}, error) {
+		return loaderFunc(typedKey[K](k))
+	})
+	return tb
+}
+
+// Set a loader function with expiration.
+// If nil returned instead of time.Duration from loaderExpireFunc than value will never expire.
+func (tb *TypedCacheBuilder[K, V]) LoaderExpireFunc(loaderExpireFunc TypedLoaderExpireFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.LoaderExpireFunc(func(k interface{}) (interface{}, *time.Duration, error) {
+		return loaderExpireFunc(typedKey[K](k))
+	})
+	return tb
+}
+
+// Set a loader function which receives the context of the caller that started the load.
+func (tb *TypedCacheBuilder[K, V]) LoaderCtxFunc(loaderCtxFunc TypedLoaderCtxFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.LoaderCtxFunc(func(ctx context.Context, k interface{}) (interface{}, error) {
+		return loaderCtxFunc(ctx, typedKey[K](k))
+	})
+	return tb
+}
+
+// Set a loader function with context and expiration.
+// If nil returned instead of time.Duration from loaderCtxExpireFunc than value will never expire.
+func (tb *TypedCacheBuilder[K, V]) LoaderCtxExpireFunc(loaderCtxExpireFunc TypedLoaderCtxExpireFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.LoaderCtxExpireFunc(func(ctx context.Context, k interface{}) (interface{}, *time.Duration, error) {
+		return loaderCtxExpireFunc(ctx, typedKey[K](k))
+	})
+	return tb
+}
+
+// Set the loader of GetMany, see CacheBuilder.BulkLoaderFunc.
+func (tb *TypedCacheBuilder[K, V]) BulkLoaderFunc(bulkLoaderFunc TypedBulkLoaderFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.BulkLoaderFunc(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		found, err := bulkLoaderFunc(typedKeys[K](keys))
+		if found == nil {
+			return nil, err
+		}
+		m := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			m[k] = v
+		}
+		return m, err
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) ExpiredFunc(expiredFunction TypedExpiredFunction[K]) *TypedCacheBuilder[K, V] {
+	tb.cb.ExpiredFunc(func(k interface{}) bool {
+		return expiredFunction(typedKey[K](k))
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) EvictedFunc(evictedFunc TypedEvictedFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.EvictedFunc(func(k, v interface{}) {
+		evictedFunc(typedKey[K](k), tb.storedValue(k, v))
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) PurgeVisitorFunc(purgeVisitorFunc TypedPurgeVisitorFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.PurgeVisitorFunc(func(k, v interface{}) {
+		purgeVisitorFunc(typedKey[K](k), tb.storedValue(k, v))
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) AddedFunc(addedFunc TypedAddedFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.cb.AddedFunc(func(k, v interface{}) {
+		addedFunc(typedKey[K](k), tb.storedValue(k, v))
+	})
+	return tb
+}
+
+// Set the function which turns a value into its stored form, it is applied to every value set or loaded.
+// It needs a DeserializeFunc, which also turns the stored values back for Weigher, AddedFunc,
+// EvictedFunc and PurgeVisitorFunc. SortKeysFunc and SearchCompareFunction can not be used with it.
+func (tb *TypedCacheBuilder[K, V]) SerializeFunc(serializeFunc TypedSerializeFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.serialized = true
+	tb.cb.SerializeFunc(func(k, v interface{}) (interface{}, error) {
+		return serializeFunc(typedKey[K](k), typedValue[V](v))
+	})
+	return tb
+}
+
+// Set the function which turns a stored value back into a V, it is applied to every value read.
+func (tb *TypedCacheBuilder[K, V]) DeserializeFunc(deserializeFunc TypedDeserializeFunc[K, V]) *TypedCacheBuilder[K, V] {
+	tb.deserializeFunc = deserializeFunc
+	tb.cb.DeserializeFunc(func(k, v interface{}) (interface{}, error) {
+		return deserializeFunc(typedKey[K](k), v)
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) SortKeysFunc(sortKeysFunction TypedSortKeysFunction[K, V]) *TypedCacheBuilder[K, V] {
+	tb.unkeyed = true
+	tb.cb.SortKeysFunc(func(keys []interface{}, values []interface{}, getItem func(interface{}) (interface{}, bool)) ([]interface{}, bool) {
+		sorted, ok := sortKeysFunction(typedKeys[K](keys), typedValues[V](values), func(k K) (V, bool) {
+			v, ok := getItem(k)
+			return typedValue[V](v), ok
+		})
+		return untypedKeys(sorted), ok
+	})
+	return tb
+}
+
+func (tb *TypedCacheBuilder[K, V]) SearchCompareFunction(searchFunction TypedSearchCompareFunction[V]) *TypedCacheBuilder[K, V] {
+	tb.unkeyed = true
+	tb.cb.SearchCompareFunction(func(value interface{}, anotherValue interface{}) int {
+		return searchFunction(typedValue[V](value), typedValue[V](anotherValue))
+	})
+	return tb
+}
+
+// storedValue converts a value the cache passes to a callback. With a SerializeFunc it is the stored
+// form, which is deserialized first. A value which can not be deserialized becomes the zero value.
+func (tb *TypedCacheBuilder[K, V]) storedValue(k, v interface{}) V {
+	if !tb.serialized || v == nil {
+		return typedValue[V](v)
+	}
+	tv, err := tb.deserializeFunc(typedKey[K](k), v)
+	if err != nil {
+		var zero V
+		return zero
+	}
+	return tv
+}
+
+// check rejects the callbacks which could not get V values with a SerializeFunc.
+func (tb *TypedCacheBuilder[K, V]) check() {
+	if !tb.serialized {
+		return
+	}
+	if tb.deserializeFunc == nil {
+		panic("gcache: typed SerializeFunc needs a DeserializeFunc")
+	}
+	if tb.unkeyed {
+		panic("gcache: typed SortKeysFunc and SearchCompareFunction can not be used with SerializeFunc")
+	}
+}
+
+func (tb *TypedCacheBuilder[K, V]) Build() *TypedCache[K, V] {
+	tb.check()
+	c := tb.cb.Build()
+	return &TypedCache[K, V]{statsAccessor: c, c: c}
+}
+
+func (tb *TypedCacheBuilder[K, V]) BuildOrderedCache() *TypedOrderedCache[K, V] {
+	tb.check()
+	c := tb.cb.BuildOrderedCache()
+	return &TypedOrderedCache[K, V]{statsAccessor: c, c: c}
+}
+
+// BuildPriorityQueue builds an OrderedCache backed by a binary heap, see CacheBuilder.BuildPriorityQueue.
+func (tb *TypedCacheBuilder[K, V]) BuildPriorityQueue() *TypedOrderedCache[K, V] {
+	tb.check()
+	c := tb.cb.BuildPriorityQueue()
+	return &TypedOrderedCache[K, V]{statsAccessor: c, c: c}
+}
+
+// TypedCache is a type-safe view of a Cache.
+type TypedCache[K comparable, V any] struct {
+	statsAccessor
+	c Cache
+}
+
+// Untyped returns the underlying interface{} cache.
+func (tc *TypedCache[K, V]) Untyped() Cache {
+	return tc.c
+}
+
+// Set a new key-value pair
+func (tc *TypedCache[K, V]) Set(key K, value V) error {
+	return tc.c.Set(key, value)
+}
+
+// Set a new key-value pair with an expiration time
+func (tc *TypedCache[K, V]) SetWithExpire(key K, value V, expiration time.Duration) error {
+	return tc.c.SetWithExpire(key, value, expiration)
+}
+
+// Get a value from cache pool using key if it exists.
+// If it dose not exists key and has LoaderFunc,
+// generate a value using `LoaderFunc` method returns value.
+func (tc *TypedCache[K, V]) Get(key K) (V, error) {
+	v, err := tc.c.Get(key)
+	return typedValue[V](v), err
+}
+
+// GetCtx works like Get, but stops waiting for the loader once ctx is done.
+func (tc *TypedCache[K, V]) GetCtx(ctx context.Context, key K) (V, error) {
+	v, err := tc.c.GetCtx(ctx, key)
+	return typedValue[V](v), err
+}
+
+// Get a value from cache pool using key if it exists.
+// If it dose not exists key, returns KeyNotFoundError.
+// And send a request which refresh value for specified key if cache object has LoaderFunc.
+func (tc *TypedCache[K, V]) GetIFPresent(key K) (V, error) {
+	v, err := tc.c.GetIFPresent(key)
+	return typedValue[V](v), err
+}
+
+// GetMany returns the values of keys which are stored or can be loaded.
+func (tc *TypedCache[K, V]) GetMany(keys []K) (map[K]V, error) {
+	m, err := tc.c.GetMany(untypedKeys(keys))
+	return typedMap[K, V](m), err
+}
+
+// Returns all key-value pairs in the cache.
+func (tc *TypedCache[K, V]) GetALL() map[K]V {
+	return typedMap[K, V](tc.c.GetALL())
+}
+
+// GetOrSet returns the value of key if it is stored, otherwise it sets value and returns it.
+func (tc *TypedCache[K, V]) GetOrSet(key K, value V) (V, bool, error) {
+	v, loaded, err := tc.c.GetOrSet(key, value)
+	return typedValue[V](v), loaded, err
+}
+
+// SetIfAbsent sets value if key is not stored, and reports whether it did.
+func (tc *TypedCache[K, V]) SetIfAbsent(key K, value V) (bool, error) {
+	return tc.c.SetIfAbsent(key, value)
+}
+
+// Compute atomically replaces the value of key with the result of f, see Cache.Compute.
+func (tc *TypedCache[K, V]) Compute(key K, f TypedComputeFunc[V]) (V, error) {
+	v, err := tc.c.Compute(key, func(old interface{}, exists bool) (interface{}, bool) {
+		return f(typedValue[V](old), exists)
+	})
+	return typedValue[V](v), err
+}
+
+// CompareAndSwap sets new if the value of key equals old, and reports whether it did.
+// The values are compared with ==, if old is not comparable it returns NotComparableErr.
+func (tc *TypedCache[K, V]) CompareAndSwap(key K, old, new V) (bool, error) {
+	return tc.c.CompareAndSwap(key, old, new)
+}
+
+// Removes the provided key from the cache.
+func (tc *TypedCache[K, V]) Remove(key K) bool {
+	return tc.c.Remove(key)
+}
+
+// Completely clear the cache
+func (tc *TypedCache[K, V]) Purge() {
+	tc.c.Purge()
+}
+
+// Returns a slice of the keys in the cache.
+func (tc *TypedCache[K, V]) Keys() []K {
+	return typedKeys[K](tc.c.Keys())
+}
+
+// Returns the number of items in the cache.
+func (tc *TypedCache[K, V]) Len() int {
+	return tc.c.Len()
+}
+
+// Save writes a snapshot of the cache to w.
+func (tc *TypedCache[K, V]) Save(w io.Writer) error {
+	return tc.c.Save(w)
+}
+
+// Load replaces the contents of the cache with a snapshot written by Save.
+func (tc *TypedCache[K, V]) Load(r io.Reader) error {
+	return tc.c.Load(r)
+}
+
+// Close stops the background janitor, if any.
+func (tc *TypedCache[K, V]) Close() error {
+	return tc.c.Close()
+}
+
+func (tc *TypedCache[K, V]) Capacity() int {
+	return tc.c.Capacity()
+}
+
+// TypedOrderedCache is a type-safe view of an OrderedCache.
+type TypedOrderedCache[K comparable, V any] struct {
+	statsAccessor
+	c OrderedCache
+}
+
+// Untyped returns the underlying interface{} ordered cache.
+func (tc *TypedOrderedCache[K, V]) Untyped() OrderedCache {
+	return tc.c
+}
+
+func (tc *TypedOrderedCache[K, V]) EnQueue(key K, value V) error {
+	return tc.c.EnQueue(key, value)
+}
+
+// EnQueueBatch adds keys to the back of the queue, or to their sorted positions.
+func (tc *TypedOrderedCache[K, V]) EnQueueBatch(keys []K, values []V) error {
+	return tc.c.EnQueueBatch(untypedKeys(keys), untypedValues(values))
+}
+
+func (tc *TypedOrderedCache[K, V]) DeQueue() (K, V, error) {
+	k, v, err := tc.c.DeQueue()
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+func (tc *TypedOrderedCache[K, V]) DeQueueBatch(count int) ([]K, []V, error) {
+	keys, values, err := tc.c.DeQueueBatch(count)
+	return typedKeys[K](keys), typedValues[V](values), err
+}
+
+// DeQueueWait works like DeQueue, but blocks until an item is queued or ctx is done.
+func (tc *TypedOrderedCache[K, V]) DeQueueWait(ctx context.Context) (K, V, error) {
+	k, v, err := tc.c.DeQueueWait(ctx)
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+// DeQueueBatchWait works like DeQueueBatch, but blocks until an item is queued or ctx is done.
+// Once it has taken an item it waits up to maxWait for more, it returns as soon as it has max items.
+func (tc *TypedOrderedCache[K, V]) DeQueueBatchWait(ctx context.Context, max int, maxWait time.Duration) ([]K, []V, error) {
+	keys, values, err := tc.c.DeQueueBatchWait(ctx, max, maxWait)
+	return typedKeys[K](keys), typedValues[V](values), err
+}
+
+func (tc *TypedOrderedCache[K, V]) OrderedKeys() []K {
+	return typedKeys[K](tc.c.OrderedKeys())
+}
+
+// move an element to front
+func (tc *TypedOrderedCache[K, V]) MoveFront(key K) error {
+	return tc.c.MoveFront(key)
+}
+
+// get top element
+func (tc *TypedOrderedCache[K, V]) GetTop() (K, V, error) {
+	k, v, err := tc.c.GetTop()
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+func (tc *TypedOrderedCache[K, V]) Prepend(key K, value V) error {
+	return tc.c.Prepend(key, value)
+}
+
+// PrependBatch adds keys to the front of the queue, or to their sorted positions.
+func (tc *TypedOrderedCache[K, V]) PrependBatch(keys []K, values []V) error {
+	return tc.c.PrependBatch(untypedKeys(keys), untypedValues(values))
+}
+
+func (tc *TypedOrderedCache[K, V]) RemoveExpired(allowFailCount int) error {
+	return tc.c.RemoveExpired(allowFailCount)
+}
+
+func (tc *TypedOrderedCache[K, V]) Get(key K) (V, error) {
+	v, err := tc.c.Get(key)
+	return typedValue[V](v), err
+}
+
+func (tc *TypedOrderedCache[K, V]) GetCtx(ctx context.Context, key K) (V, error) {
+	v, err := tc.c.GetCtx(ctx, key)
+	return typedValue[V](v), err
+}
+
+func (tc *TypedOrderedCache[K, V]) GetIFPresent(key K) (V, error) {
+	v, err := tc.c.GetIFPresent(key)
+	return typedValue[V](v), err
+}
+
+func (tc *TypedOrderedCache[K, V]) GetALL() map[K]V {
+	return typedMap[K, V](tc.c.GetALL())
+}
+
+func (tc *TypedOrderedCache[K, V]) GetKeysAndValues() ([]K, []V) {
+	keys, values := tc.c.GetKeysAndValues()
+	return typedKeys[K](keys), typedValues[V](values)
+}
+
+func (tc *TypedOrderedCache[K, V]) Remove(key K) bool {
+	return tc.c.Remove(key)
+}
+
+func (tc *TypedOrderedCache[K, V]) PrintValues(stamp int) {
+	tc.c.PrintValues(stamp)
+}
+
+func (tc *TypedOrderedCache[K, V]) Purge() {
+	tc.c.Purge()
+}
+
+func (tc *TypedOrderedCache[K, V]) Refresh() {
+	tc.c.Refresh()
+}
+
+func (tc *TypedOrderedCache[K, V]) Keys() []K {
+	return typedKeys[K](tc.c.Keys())
+}
+
+func (tc *TypedOrderedCache[K, V]) Len() int {
+	return tc.c.Len()
+}
+
+func (tc *TypedOrderedCache[K, V]) Sort() {
+	tc.c.Sort()
+}
+
+// Range calls fn in order for the keys with values between from and to, both included, until fn returns false.
+func (tc *TypedOrderedCache[K, V]) Range(from, to V, fn func(k K, v V) bool) {
+	tc.c.Range(from, to, func(k, v interface{}) bool {
+		return fn(typedKey[K](k), typedValue[V](v))
+	})
+}
+
+// Floor returns the last key with a value less than or equal to value.
+func (tc *TypedOrderedCache[K, V]) Floor(value V) (K, V, error) {
+	k, v, err := tc.c.Floor(value)
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+// Ceiling returns the first key with a value greater than or equal to value.
+func (tc *TypedOrderedCache[K, V]) Ceiling(value V) (K, V, error) {
+	k, v, err := tc.c.Ceiling(value)
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+// Rank returns the position of key in the sort order, or -1 if it is not stored.
+func (tc *TypedOrderedCache[K, V]) Rank(key K) int {
+	return tc.c.Rank(key)
+}
+
+// At returns the key at position i of the sort order.
+func (tc *TypedOrderedCache[K, V]) At(i int) (K, V, error) {
+	k, v, err := tc.c.At(i)
+	return typedKey[K](k), typedValue[V](v), err
+}
+
+// UpdateScore sets the value of key and moves it to its new position, a missing key is added.
+func (tc *TypedOrderedCache[K, V]) UpdateScore(key K, value V) error {
+	return tc.c.UpdateScore(key, value)
+}
+
+// TopN returns up to n keys from the front of the sort order.
+func (tc *TypedOrderedCache[K, V]) TopN(n int) ([]K, []V) {
+	keys, values := tc.c.TopN(n)
+	return typedKeys[K](keys), typedValues[V](values)
+}
+
+// BottomN returns up to n keys from the back of the sort order, the last key first.
+func (tc *TypedOrderedCache[K, V]) BottomN(n int) ([]K, []V) {
+	keys, values := tc.c.BottomN(n)
+	return typedKeys[K](keys), typedValues[V](values)
+}
+
+// RankOf returns the position of key in the sort order, KeyNotFoundError if key is missing or expired.
+func (tc *TypedOrderedCache[K, V]) RankOf(key K) (int, error) {
+	return tc.c.RankOf(key)
+}
+
+// RemoveRangeByRank removes the keys from position start to stop, both included, and returns how many it removed.
+func (tc *TypedOrderedCache[K, V]) RemoveRangeByRank(start, stop int) int {
+	return tc.c.RemoveRangeByRank(start, stop)
+}
+
+func (tc *TypedOrderedCache[K, V]) Save(w io.Writer) error {
+	return tc.c.Save(w)
+}
+
+func (tc *TypedOrderedCache[K, V]) Load(r io.Reader) error {
+	return tc.c.Load(r)
+}
+
+func (tc *TypedOrderedCache[K, V]) Close() error {
+	return tc.c.Close()
+}
+
+func (tc *TypedOrderedCache[K, V]) Capacity() int {
+	return tc.c.Capacity()
+}
+
+func (tc *TypedOrderedCache[K, V]) QueueLen() int {
+	return tc.c.QueueLen()
+}
+
+// typedKey converts a key coming out of an untyped cache, nil becomes the zero key.
+func typedKey[K comparable](k interface{}) K {
+	if k == nil {
+		var zero K
+		return zero
+	}
+	return k.(K)
+}
+
+// typedValue converts a value coming out of an untyped cache, nil becomes the zero value.
+func typedValue[V any](v interface{}) V {
+	if v == nil {
+		var zero V
+		return zero
+	}
+	return v.(V)
+}
+
+func typedKeys[K comparable](keys []interface{}) []K {
+	if keys == nil {
+		return nil
+	}
+	ks := make([]K, len(keys))
+	for i, k := range keys {
+		ks[i] = typedKey[K](k)
+	}
+	return ks
+}
+
+func typedValues[V any](values []interface{}) []V {
+	if values == nil {
+		return nil
+	}
+	vs := make([]V, len(values))
+	for i, v := range values {
+		vs[i] = typedValue[V](v)
+	}
+	return vs
+}
+
+func typedMap[K comparable, V any](m map[interface{}]interface{}) map[K]V {
+	tm := make(map[K]V, len(m))
+	for k, v := range m {
+		tm[typedKey[K](k)] = typedValue[V](v)
+	}
+	return tm
+}
+
+func untypedKeys[K comparable](keys []K) []interface{} {
+	if keys == nil {
+		return nil
+	}
+	ks := make([]interface{}, len(keys))
+	for i, k := range keys {
+		ks[i] = k
+	}
+	return ks
+}
+
+func untypedValues[V any](values []V) []interface{} {
+	if values == nil {
+		return nil
+	}
+	vs := make([]interface{}, len(values))
+	for i, v := range values {
+		vs[i] = v
+	}
+	return vs
+}
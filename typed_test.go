@@ -0,0 +1,187 @@
+package gcache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTypedCacheGet(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		var evicted []string
+		gc := NewTyped[string, int](2).
+			EvictType(tp).
+			LoaderFunc(func(key string) (int, error) {
+				return len(key), nil
+			}).
+			EvictedFunc(func(key string, value int) {
+				evicted = append(evicted, key)
+			}).
+			Build()
+
+		v, err := gc.Get("four")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tp, err)
+		}
+		if v != 4 {
+			t.Errorf("%s: %v != %v", tp, v, 4)
+		}
+		if err := gc.Set("key", 42); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tp, err)
+		}
+		if v, _ := gc.GetIFPresent("key"); v != 42 {
+			t.Errorf("%s: %v != %v", tp, v, 42)
+		}
+		gc.Set("third", 3)
+		if len(evicted) == 0 {
+			t.Errorf("%s: expected an eviction", tp)
+		}
+		m := gc.GetALL()
+		if len(m) != gc.Len() {
+			t.Errorf("%s: %v != %v", tp, len(m), gc.Len())
+		}
+		for _, k := range gc.Keys() {
+			if _, ok := m[k]; !ok {
+				t.Errorf("%s: GetALL should contain %v", tp, k)
+			}
+		}
+	}
+}
+
+func TestTypedCacheMissReturnsZero(t *testing.T) {
+	gc := NewTyped[int, string](8).LRU().Build()
+	v, err := gc.Get(1)
+	if err != KeyNotFoundError {
+		t.Errorf("err should be KeyNotFoundError, not %v", err)
+	}
+	if v != "" {
+		t.Errorf("v should be zero value, not %q", v)
+	}
+	if gc.MissCount() != 1 {
+		t.Errorf("%v != %v", gc.MissCount(), 1)
+	}
+}
+
+func TestTypedCacheSerializeAndCtxExpireLoader(t *testing.T) {
+	clock := NewFakeClock()
+	gc := NewTyped[string, int](10).
+		Clock(clock).
+		LoaderCtxExpireFunc(func(ctx context.Context, key string) (int, *time.Duration, error) {
+			d := time.Minute
+			return len(key), &d, nil
+		}).
+		SerializeFunc(func(key string, value int) (interface{}, error) {
+			return strconv.Itoa(value), nil
+		}).
+		DeserializeFunc(func(key string, value interface{}) (int, error) {
+			return strconv.Atoi(value.(string))
+		}).
+		Build()
+
+	if v, err := gc.GetCtx(context.Background(), "abc"); err != nil || v != 3 {
+		t.Errorf("expected 3, got %v, %v", v, err)
+	}
+	if v, err := gc.Untyped().GetIFPresent("abc"); err != nil || v != 3 {
+		t.Errorf("expected the deserialized value, got %v, %v", v, err)
+	}
+	gc.Set("x", 42)
+	if v, err := gc.Get("x"); err != nil || v != 42 {
+		t.Errorf("expected 42, got %v, %v", v, err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := gc.GetIFPresent("abc"); err != KeyNotFoundError {
+		t.Errorf("the loaded value should expire, got %v", err)
+	}
+}
+
+func TestTypedCacheSerializeWithCallbacks(t *testing.T) {
+	var added, evicted []int
+	gc := NewTyped[string, int](10).
+		LRU().
+		SerializeFunc(func(key string, value int) (interface{}, error) {
+			return strconv.Itoa(value), nil
+		}).
+		DeserializeFunc(func(key string, value interface{}) (int, error) {
+			return strconv.Atoi(value.(string))
+		}).
+		AddedFunc(func(key string, value int) {
+			added = append(added, value)
+		}).
+		EvictedFunc(func(key string, value int) {
+			evicted = append(evicted, value)
+		}).
+		Weigher(func(key string, value int) int64 {
+			return int64(value)
+		}).
+		MaxWeight(10).
+		Build()
+
+	gc.Set("a", 4)
+	gc.Set("b", 5)
+	gc.Set("c", 3)
+	if fmt.Sprint(added) != "[4 5 3]" || fmt.Sprint(evicted) != "[4]" {
+		t.Errorf("added %v, evicted %v", added, evicted)
+	}
+	if v, err := gc.Get("c"); err != nil || v != 3 {
+		t.Errorf("expected 3, got %v, %v", v, err)
+	}
+}
+
+func TestTypedSerializeFuncChecks(t *testing.T) {
+	serialize := func(key int, value int) (interface{}, error) {
+		return strconv.Itoa(value), nil
+	}
+	deserialize := func(key int, value interface{}) (int, error) {
+		return strconv.Atoi(value.(string))
+	}
+	for name, build := range map[string]func(){
+		"without DeserializeFunc": func() {
+			NewTyped[int, int](10).SerializeFunc(serialize).Build()
+		},
+		"with SearchCompareFunction": func() {
+			NewTyped[int, int](10).SerializeFunc(serialize).DeserializeFunc(deserialize).
+				SearchCompareFunction(func(a, b int) int { return a - b }).BuildOrderedCache()
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: the build should panic", name)
+				}
+			}()
+			build()
+		}()
+	}
+}
+
+func TestTypedOrderedCache(t *testing.T) {
+	c := NewTyped[int, int](100).
+		SearchCompareFunction(func(a, b int) int {
+			return a - b
+		}).
+		BuildOrderedCache()
+	for i := 10; i > 0; i-- {
+		if err := c.EnQueue(i, i*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, v, err := c.GetTop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != 1 || v != 10 {
+		t.Errorf("top should be 1:10, not %v:%v", k, v)
+	}
+	keys, values, err := c.DeQueueBatch(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[1 2 3]" || fmt.Sprint(values) != "[10 20 30]" {
+		t.Errorf("unexpected batch %v %v", keys, values)
+	}
+	if c.Len() != 7 {
+		t.Errorf("%v != %v", c.Len(), 7)
+	}
+}
@@ -0,0 +1,41 @@
+package gcache
+
+import "fmt"
+
+// WeightExceededError is returned when an item weighs more than MaxWeight on its own.
+type WeightExceededError struct {
+	Key       interface{}
+	Weight    int64
+	MaxWeight int64
+}
+
+func (e *WeightExceededError) Error() string {
+	return fmt.Sprintf("weight %d of key %v exceeds max weight %d", e.Weight, e.Key, e.MaxWeight)
+}
+
+// weigh returns the weight of an item, or an error if it can never fit into the cache.
+func (c *baseCache) weigh(key, value interface{}) (int64, error) {
+	var w int64 = 1
+	if c.weigher != nil {
+		w = c.weigher(key, value)
+	}
+	if c.maxWeight > 0 && w > c.maxWeight {
+		return w, &WeightExceededError{Key: key, Weight: w, MaxWeight: c.maxWeight}
+	}
+	return w, nil
+}
+
+// overweight reports whether the cache goes over MaxWeight if extra is added.
+func (c *baseCache) overweight(extra int64) bool {
+	return c.maxWeight > 0 && c.weight+extra > c.maxWeight
+}
+
+// makeRoom evicts items until an item of weight w fits. replaced returns the weight of the
+// item which is going to be overwritten, evict removes one item and reports whether it did.
+func (c *baseCache) makeRoom(w int64, replaced func() int64, evict func() bool) {
+	if c.maxWeight <= 0 {
+		return
+	}
+	for c.overweight(w-replaced()) && evict() {
+	}
+}
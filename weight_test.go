@@ -0,0 +1,180 @@
+package gcache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func weighString(key, value interface{}) int64 {
+	return int64(len(value.(string)))
+}
+
+func cacheWeight(gc Cache) int64 {
+	switch c := gc.(type) {
+	case *SimpleCache:
+		return c.weight
+	case *LRUCache:
+		return c.weight
+	case *LFUCache:
+		return c.weight
+	case *ARC:
+		return c.weight
+	case *TinyLFUCache:
+		return c.weight
+	case *TwoQueueCache:
+		return c.weight
+	case *SLRUCache:
+		return c.weight
+	case *SieveCache:
+		return c.weight
+	case *S3FIFOCache:
+		return c.weight
+	}
+	panic("unknown cache")
+}
+
+func TestMaxWeight(t *testing.T) {
+	for _, tp := range []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_TINYLFU, TYPE_2Q, TYPE_SLRU, TYPE_SIEVE, TYPE_S3FIFO} {
+		gc := New(100).EvictType(tp).Weigher(weighString).MaxWeight(10).Build()
+		gc.Set("a", "aaaa")
+		gc.Set("b", "bbbb")
+		if err := gc.Set("c", "cccc"); err != nil {
+			t.Fatalf("%s: %v", tp, err)
+		}
+		if n := gc.Len(); n != 2 {
+			t.Errorf("%s: %v != %v", tp, n, 2)
+		}
+		if _, err := gc.Get("c"); err != nil {
+			t.Errorf("%s: the new item should be stored: %v", tp, err)
+		}
+		if w := cacheWeight(gc); w != 8 {
+			t.Errorf("%s: %v != %v", tp, w, 8)
+		}
+
+		// a heavier value for a stored key evicts everything else
+		if err := gc.Set("c", "ccccccccc"); err != nil {
+			t.Fatalf("%s: %v", tp, err)
+		}
+		if keys := gc.Keys(); len(keys) != 1 || keys[0] != "c" {
+			t.Errorf("%s: unexpected keys %v", tp, keys)
+		}
+		if w := cacheWeight(gc); w != 9 {
+			t.Errorf("%s: %v != %v", tp, w, 9)
+		}
+
+		err := gc.Set("big", "bbbbbbbbbbb")
+		var wErr *WeightExceededError
+		if !errors.As(err, &wErr) || wErr.Weight != 11 || wErr.MaxWeight != 10 {
+			t.Errorf("%s: unexpected error %v", tp, err)
+		}
+		if _, err := gc.Get("c"); err != nil {
+			t.Errorf("%s: a rejected item should not evict anything: %v", tp, err)
+		}
+
+		gc.Remove("c")
+		if w := cacheWeight(gc); w != 0 {
+			t.Errorf("%s: %v != %v", tp, w, 0)
+		}
+	}
+}
+
+func TestMaxWeightWithoutWeigher(t *testing.T) {
+	gc := New(100).LRU().MaxWeight(3).Build()
+	for i := 0; i < 10; i++ {
+		gc.Set(i, i)
+	}
+	if n := gc.Len(); n != 3 {
+		t.Errorf("%v != %v", n, 3)
+	}
+}
+
+func TestMaxWeightLoader(t *testing.T) {
+	gc := New(10).LRU().Weigher(weighString).MaxWeight(3).LoaderFunc(func(key interface{}) (interface{}, error) {
+		return key, nil
+	}).Build()
+	if _, err := gc.Get("ab"); err != nil {
+		t.Error(err)
+	}
+	var wErr *WeightExceededError
+	if _, err := gc.Get("abcd"); !errors.As(err, &wErr) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMaxWeightOrderedCache(t *testing.T) {
+	c := New(100).Weigher(weighString).MaxWeight(10).BuildOrderedCache()
+	c.EnQueue(1, "aaaa")
+	c.EnQueue(2, "bbbb")
+	c.EnQueue(3, "cccc")
+	if keys := c.OrderedKeys(); len(keys) != 2 || keys[0] != 2 || keys[1] != 3 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if err := c.EnQueueBatch([]interface{}{4, 5}, []interface{}{"dddddd", "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := c.(*SimpleOrderedCache).weight; w > 10 {
+		t.Errorf("weight %v is over the limit", w)
+	}
+	var wErr *WeightExceededError
+	if err := c.Prepend(6, "ffffffffffff"); !errors.As(err, &wErr) {
+		t.Errorf("unexpected error %v", err)
+	}
+	c.DeQueue()
+	c.Purge()
+	if w := c.(*SimpleOrderedCache).weight; w != 0 {
+		t.Errorf("%v != %v", w, 0)
+	}
+}
+
+func TestMaxWeightLoad(t *testing.T) {
+	src := New(10).LRU().Build()
+	src.Set("a", "aaaa")
+	src.Set("b", "bbbb")
+	src.Set("c", "cccc")
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := New(10).LRU().Weigher(weighString).MaxWeight(10).Build()
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.Keys(); len(keys) != 2 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	if _, err := dst.Get("c"); err != nil {
+		t.Error("the most recently used items should be kept")
+	}
+}
+
+func TestShardedMaxWeight(t *testing.T) {
+	gc := New(100).Shards(4).LRU().Weigher(weighString).MaxWeight(40).Build()
+	// MaxWeight is global, an item may weigh more than MaxWeight/shards
+	if err := gc.Set("a", strings.Repeat("a", 25)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		gc.Set(i, "bb")
+	}
+	sc := gc.(*ShardedCache)
+	if w := shardedWeight(sc); w > 40 || w != sc.weight {
+		t.Errorf("weight %v, global weight %v, limit %v", w, sc.weight, 40)
+	}
+	if _, err := gc.Get(19); err != nil {
+		t.Error("the item which was just set should be kept")
+	}
+	var wErr *WeightExceededError
+	if err := gc.Set("big", strings.Repeat("b", 41)); !errors.As(err, &wErr) || wErr.MaxWeight != 40 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func shardedWeight(c *ShardedCache) int64 {
+	var w int64
+	for _, s := range c.shards {
+		w += cacheWeight(s)
+	}
+	return w
+}